@@ -0,0 +1,211 @@
+package apitest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	alogging "github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/rem/api/apitest"
+	"github.com/hellcats88/rem/logging"
+)
+
+// echoHttp is an api.Http answering every request with an envelope describing it
+type echoHttp struct {
+	routes []string
+}
+
+func (e *echoHttp) AddRoute(method string, path string, config api.Config, service api.Service) error {
+	e.routes = append(e.routes, method+" "+path)
+	return nil
+}
+
+func (e *echoHttp) Listen(port int, address string) error {
+	return errors.New("Not supported")
+}
+
+type echoData struct {
+	Method      string              `json:"method"`
+	URI         string              `json:"uri"`
+	Header      map[string][]string `json:"header"`
+	Body        string              `json:"body"`
+	ContentType string              `json:"contentType"`
+}
+
+func (e *echoHttp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(api.Model{
+		Error: api.ErrorModel{Code: api.ApiErrorNoError, CorrId: r.Header.Get("X-Correlation-ID")},
+		Data: echoData{
+			Method:      r.Method,
+			URI:         r.URL.RequestURI(),
+			Header:      r.Header,
+			Body:        string(body),
+			ContentType: r.Header.Get("Content-Type"),
+		},
+	})
+}
+
+// recordingT collects the failures reported by the assertions under test
+type recordingT struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestNewRejectsNonHandler(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("New accepted an api.Http that is not an http.Handler")
+		}
+	}()
+
+	apitest.New(struct{ api.Http }{})
+}
+
+func TestRequestBuilding(t *testing.T) {
+	e := &echoHttp{}
+	h := apitest.New(e)
+
+	if err := h.AddRoute(http.MethodPost, "/items", nil, nil); err != nil || len(e.routes) != 1 || e.routes[0] != "POST /items" {
+		t.Fatalf("AddRoute not forwarded. %v %v", err, e.routes)
+	}
+
+	var data echoData
+	h.Post("/items").
+		Tenant("t1", "u1").
+		CorrID("c1").
+		Header("X-Env", "dev").
+		Query("page", "2").
+		Query("page", "3").
+		JSON(map[string]string{"name": "a"}).
+		Do().
+		AssertStatus(t, http.StatusOK).
+		AssertErrorCode(t, api.ApiErrorNoError).
+		AssertCorrID(t, "c1").
+		AssertData(t, &data)
+
+	if data.Method != http.MethodPost || data.URI != "/items?page=2&page=3" {
+		t.Errorf("Unexpected request line %s %s", data.Method, data.URI)
+	}
+
+	if data.Body != `{"name":"a"}` || data.ContentType != "application/json" {
+		t.Errorf("Unexpected body %s of type %s", data.Body, data.ContentType)
+	}
+
+	for name, value := range map[string]string{"X-Tenant-Id": "t1", "X-Tenant-Userid": "u1", "X-Env": "dev"} {
+		if got := data.Header[name]; len(got) != 1 || got[0] != value {
+			t.Errorf("Expected header %s %s, got %v", name, value, got)
+		}
+	}
+}
+
+func TestAssertionsReportMismatches(t *testing.T) {
+	res := apitest.New(&echoHttp{}).Get("/").CorrID("c1").Do()
+
+	rec := &recordingT{TB: t}
+	res.AssertStatus(rec, http.StatusNotFound).
+		AssertErrorCode(rec, api.ApiErrorUnexpected).
+		AssertCorrID(rec, "other").
+		AssertData(rec, new(int))
+
+	if len(rec.failures) != 4 {
+		t.Errorf("Expected 4 failures, got %v", rec.failures)
+	}
+
+	if model, err := res.Model(); err != nil || model.Error.CorrId != "c1" {
+		t.Errorf("Unexpected model %+v. %v", model, err)
+	}
+}
+
+func TestStorageRecordsOutcomes(t *testing.T) {
+	s := apitest.NewStorage()
+	s.AssertNoTransaction(t)
+
+	tx, err := s.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+	s.AssertCommitted(t)
+
+	tx, _ = s.UnmanagedTx()
+	child, _ := tx.Begin()
+	tx.Rollback()
+	s.AssertRolledBack(t)
+
+	last := s.Last()
+	if last.Managed() || len(last.Children()) != 1 || last.Children()[0] != child || child.(*apitest.Transaction).Outcome() != apitest.OutcomePending {
+		t.Errorf("Unexpected unmanaged transaction %+v", last)
+	}
+
+	failure := errors.New("down")
+	s.FailCommit(failure)
+	tx, _ = s.Tx()
+	if err := tx.Commit(); err != failure {
+		t.Errorf("Expected the injected commit failure, got %v", err)
+	}
+
+	s.FailTx(failure)
+	if _, err := s.Tx(); err != failure {
+		t.Errorf("Expected the injected opening failure, got %v", err)
+	}
+
+	if n := len(s.Transactions()); n != 3 {
+		t.Errorf("Expected 3 transactions, got %d", n)
+	}
+
+	s.Reset()
+	s.AssertNoTransaction(t)
+	if _, err := s.Tx(); err != nil {
+		t.Errorf("Reset kept the injected failure. %v", err)
+	}
+}
+
+func TestLoggerRecordsEntries(t *testing.T) {
+	l := apitest.NewLogger()
+	ctx := logging.NewContext("c1")
+
+	l.Info(ctx, "Served %s", "items")
+	l.Error(ctx, "Failed %d", 2)
+
+	l.AssertLogged(t, alogging.Info, "Served items")
+	l.AssertNotLogged(t, alogging.Info, "Failed")
+
+	found := l.Find(alogging.Error, "Failed 2")
+	if len(found) != 1 || found[0].CorrID != "c1" {
+		t.Errorf("Unexpected entries %+v", found)
+	}
+
+	l.Reset()
+	if n := len(l.Entries()); n != 0 {
+		t.Errorf("Expected no entries after reset, got %d", n)
+	}
+}
+
+func TestFailAlwaysCarriesAnError(t *testing.T) {
+	out := apitest.Fail(api.ApiErrorEntityDoesNotExists, "No item", nil)
+	if out.Err() == nil || out.Err().Error() != "No item" {
+		t.Errorf("Expected an error built from the message, got %v", out.Err())
+	}
+
+	failure := errors.New("lookup failed")
+	if out := apitest.Fail(api.ApiErrorUnexpected, "Failed", failure); out.Err() != failure {
+		t.Errorf("Expected the given error, got %v", out.Err())
+	}
+
+	if out := apitest.Ok(1); out.Status() != api.ApiErrorNoError || out.ResponseModel() != 1 {
+		t.Errorf("Unexpected successful output %+v", out)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/hellcats88/rem/api/apitest
+
+go 1.15
+
+require (
+	github.com/hellcats88/abstracte v0.0.0-20210531083423-6e6de49b09ed
+	github.com/hellcats88/rem v0.0.0-20261019172829-746556a5853c
+)
@@ -0,0 +1,237 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+)
+
+// Harness serves the routes of an api.Http in memory, without opening a listener
+type Harness struct {
+	http    api.Http
+	handler http.Handler
+}
+
+// New wraps an api.Http implementation. The implementation must also be an http.Handler
+func New(h api.Http) *Harness {
+	handler, ok := h.(http.Handler)
+	if !ok {
+		panic("apitest: api.Http implementation is not an http.Handler")
+	}
+
+	return &Harness{http: h, handler: handler}
+}
+
+// AddRoute registers a route on the wrapped api.Http
+func (h *Harness) AddRoute(method string, path string, config api.Config, service api.Service) error {
+	return h.http.AddRoute(method, path, config, service)
+}
+
+// Request starts building a request for the given method and path
+func (h *Harness) Request(method string, path string) *Request {
+	return &Request{
+		harness: h,
+		method:  method,
+		path:    path,
+		header:  make(http.Header),
+		query:   make(url.Values),
+	}
+}
+
+// Get starts building a GET request
+func (h *Harness) Get(path string) *Request {
+	return h.Request(http.MethodGet, path)
+}
+
+// Post starts building a POST request
+func (h *Harness) Post(path string) *Request {
+	return h.Request(http.MethodPost, path)
+}
+
+// Put starts building a PUT request
+func (h *Harness) Put(path string) *Request {
+	return h.Request(http.MethodPut, path)
+}
+
+// Patch starts building a PATCH request
+func (h *Harness) Patch(path string) *Request {
+	return h.Request(http.MethodPatch, path)
+}
+
+// Delete starts building a DELETE request
+func (h *Harness) Delete(path string) *Request {
+	return h.Request(http.MethodDelete, path)
+}
+
+// Request is an in memory request under construction
+type Request struct {
+	harness *Harness
+	method  string
+	path    string
+	header  http.Header
+	query   url.Values
+	body    []byte
+}
+
+// Tenant sets the tenant headers read by the tenant stage
+func (r *Request) Tenant(id string, userID string) *Request {
+	r.header.Set("X-Tenant-ID", id)
+	r.header.Set("X-Tenant-UserID", userID)
+	return r
+}
+
+// CorrID sets the correlation ID header read by the log stage
+func (r *Request) CorrID(id string) *Request {
+	r.header.Set("X-Correlation-ID", id)
+	return r
+}
+
+// Header sets a request header
+func (r *Request) Header(name string, value string) *Request {
+	r.header.Set(name, value)
+	return r
+}
+
+// Query adds a query parameter
+func (r *Request) Query(name string, value string) *Request {
+	r.query.Add(name, value)
+	return r
+}
+
+// Body sets the raw request body
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.header.Set("Content-Type", contentType)
+	r.body = body
+	return r
+}
+
+// JSON sets the request body to the JSON serialization of model
+func (r *Request) JSON(model interface{}) *Request {
+	body, err := json.Marshal(model)
+	if err != nil {
+		panic("apitest: cannot serialize request model. " + err.Error())
+	}
+
+	return r.Body("application/json", body)
+}
+
+// Do sends the request to the wrapped api.Http and records the response
+func (r *Request) Do() *Response {
+	target := r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+
+	req := httptest.NewRequest(r.method, target, body)
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+
+	recorder := httptest.NewRecorder()
+	r.harness.handler.ServeHTTP(recorder, req)
+
+	return &Response{Recorder: recorder}
+}
+
+// envelope mirrors api.Model keeping the business data raw
+type envelope struct {
+	Error api.ErrorModel  `json:"err"`
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// Response is the recorded outcome of a request
+type Response struct {
+	Recorder *httptest.ResponseRecorder
+}
+
+// Status returns the HTTP status code
+func (r *Response) Status() int {
+	return r.Recorder.Code
+}
+
+// Header returns the response headers
+func (r *Response) Header() http.Header {
+	return r.Recorder.Header()
+}
+
+func (r *Response) envelope() (envelope, error) {
+	var env envelope
+	err := json.Unmarshal(r.Recorder.Body.Bytes(), &env)
+	return env, err
+}
+
+// Model decodes the response body as api.Model envelope
+func (r *Response) Model() (api.Model, error) {
+	var model api.Model
+	err := json.Unmarshal(r.Recorder.Body.Bytes(), &model)
+	return model, err
+}
+
+// Data decodes the business data of the envelope into v
+func (r *Response) Data(v interface{}) error {
+	env, err := r.envelope()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(env.Data, v)
+}
+
+// AssertStatus fails the test if the HTTP status code differs from expected
+func (r *Response) AssertStatus(t testing.TB, expected int) *Response {
+	t.Helper()
+	if r.Status() != expected {
+		t.Errorf("expected HTTP status %d, got %d. Body: %s", expected, r.Status(), r.Recorder.Body.String())
+	}
+	return r
+}
+
+// AssertErrorCode fails the test if the envelope error code differs from expected
+func (r *Response) AssertErrorCode(t testing.TB, expected api.ApiError) *Response {
+	t.Helper()
+	env, err := r.envelope()
+	if err != nil {
+		t.Errorf("response is not an api.Model envelope. %v", err)
+		return r
+	}
+
+	if env.Error.Code != expected {
+		t.Errorf("expected error code %d, got %d (%s: %s)", expected, env.Error.Code, env.Error.Msg, env.Error.DevMsg)
+	}
+	return r
+}
+
+// AssertCorrID fails the test if the envelope correlation ID differs from expected
+func (r *Response) AssertCorrID(t testing.TB, expected string) *Response {
+	t.Helper()
+	env, err := r.envelope()
+	if err != nil {
+		t.Errorf("response is not an api.Model envelope. %v", err)
+		return r
+	}
+
+	if env.Error.CorrId != expected {
+		t.Errorf("expected correlation ID %s, got %s", expected, env.Error.CorrId)
+	}
+	return r
+}
+
+// AssertData fails the test if the envelope data cannot be decoded into v
+func (r *Response) AssertData(t testing.TB, v interface{}) *Response {
+	t.Helper()
+	if err := r.Data(v); err != nil {
+		t.Errorf("cannot decode envelope data. %v", err)
+	}
+	return r
+}
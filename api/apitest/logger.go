@@ -0,0 +1,120 @@
+package apitest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hellcats88/abstracte/logging"
+)
+
+// Entry is a recorded log message
+type Entry struct {
+	Level   logging.Level
+	CorrID  string
+	Message string
+	Extras  []logging.K
+}
+
+// Logger is a logging.Logger recording every message in memory
+type Logger struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewLogger creates an empty recording logger
+func NewLogger() *Logger {
+	return &Logger{}
+}
+
+func (l *Logger) record(level logging.Level, ctx logging.Context, msg string, params ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, Entry{
+		Level:   level,
+		CorrID:  ctx.CorrID(),
+		Message: fmt.Sprintf(msg, params...),
+		Extras:  ctx.Extras(),
+	})
+}
+
+func (l *Logger) Info(ctx logging.Context, msg string, params ...interface{}) {
+	l.record(logging.Info, ctx, msg, params...)
+}
+
+func (l *Logger) Debug(ctx logging.Context, msg string, params ...interface{}) {
+	l.record(logging.Debug, ctx, msg, params...)
+}
+
+func (l *Logger) Trace(ctx logging.Context, msg string, params ...interface{}) {
+	l.record(logging.Trace, ctx, msg, params...)
+}
+
+func (l *Logger) Error(ctx logging.Context, msg string, params ...interface{}) {
+	l.record(logging.Error, ctx, msg, params...)
+}
+
+func (l *Logger) Warn(ctx logging.Context, msg string, params ...interface{}) {
+	l.record(logging.Warn, ctx, msg, params...)
+}
+
+func (l *Logger) BeginMethod(ctx logging.Context) {
+	l.record(logging.Debug, ctx, "Begin")
+}
+
+func (l *Logger) BeginMethodParams(ctx logging.Context, format string, params ...interface{}) {
+	l.record(logging.Debug, ctx, "Begin "+format, params...)
+}
+
+func (l *Logger) EndMethod(ctx logging.Context) {
+	l.record(logging.Debug, ctx, "End")
+}
+
+func (l *Logger) EndMethodParams(ctx logging.Context, format string, params ...interface{}) {
+	l.record(logging.Debug, ctx, "End "+format, params...)
+}
+
+// Entries returns all recorded messages in emission order
+func (l *Logger) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]Entry(nil), l.entries...)
+}
+
+// Find returns the recorded messages of level containing text
+func (l *Logger) Find(level logging.Level, text string) []Entry {
+	var found []Entry
+	for _, e := range l.Entries() {
+		if e.Level == level && strings.Contains(e.Message, text) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// Reset forgets all recorded messages
+func (l *Logger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = nil
+}
+
+// AssertLogged fails the test if no message of level contains text
+func (l *Logger) AssertLogged(t testing.TB, level logging.Level, text string) {
+	t.Helper()
+	if len(l.Find(level, text)) == 0 {
+		t.Errorf("expected a log message of level %d containing %q", level, text)
+	}
+}
+
+// AssertNotLogged fails the test if any message of level contains text
+func (l *Logger) AssertNotLogged(t testing.TB, level logging.Level, text string) {
+	t.Helper()
+	if found := l.Find(level, text); len(found) > 0 {
+		t.Errorf("unexpected log message of level %d: %q", level, found[0].Message)
+	}
+}
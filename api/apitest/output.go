@@ -0,0 +1,45 @@
+package apitest
+
+import (
+	"errors"
+
+	"github.com/hellcats88/abstracte/api"
+)
+
+// Output is a ready made api.ServiceOutput for stub services
+type Output struct {
+	Code    api.ApiError
+	Error   error
+	Message string
+	Data    interface{}
+}
+
+// Ok returns a successful output carrying data
+func Ok(data interface{}) Output {
+	return Output{Code: api.ApiErrorNoError, Data: data}
+}
+
+// Fail returns a failed output with the given status, message and error.
+// A nil err is replaced by an error carrying msg, as failed outputs always have one
+func Fail(code api.ApiError, msg string, err error) Output {
+	if err == nil {
+		err = errors.New(msg)
+	}
+	return Output{Code: code, Message: msg, Error: err}
+}
+
+func (o Output) Status() api.ApiError {
+	return o.Code
+}
+
+func (o Output) Err() error {
+	return o.Error
+}
+
+func (o Output) ErrMessage() string {
+	return o.Message
+}
+
+func (o Output) ResponseModel() interface{} {
+	return o.Data
+}
@@ -0,0 +1,265 @@
+package apitest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hellcats88/abstracte/storage"
+	rstorage "github.com/hellcats88/rem/storage"
+)
+
+// Outcome is the final state of a recorded transaction
+type Outcome int
+
+const (
+	// OutcomePending means the transaction has been neither committed nor rolled back
+	OutcomePending Outcome = 0x0
+
+	// OutcomeCommitted means the transaction has been committed
+	OutcomeCommitted Outcome = 0x1
+
+	// OutcomeRolledBack means the transaction has been rolled back
+	OutcomeRolledBack Outcome = 0x2
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeCommitted:
+		return "committed"
+	case OutcomeRolledBack:
+		return "rolled back"
+	default:
+		return "pending"
+	}
+}
+
+// Transaction is a fake storage.Transaction recording its outcome
+type Transaction struct {
+	mu        sync.Mutex
+	managed   bool
+	outcome   Outcome
+	commitErr error
+	children  []*Transaction
+	options   rstorage.TxOptions
+	deadline  time.Time
+}
+
+func (t *Transaction) Ref() interface{} {
+	return t
+}
+
+func (t *Transaction) Query() interface{} {
+	return t
+}
+
+func (t *Transaction) Commit() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.commitErr != nil {
+		return t.commitErr
+	}
+
+	t.outcome = OutcomeCommitted
+	return nil
+}
+
+func (t *Transaction) Rollback() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.outcome = OutcomeRolledBack
+	return nil
+}
+
+func (t *Transaction) Begin() (storage.Transaction, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	child := &Transaction{managed: t.managed}
+	t.children = append(t.children, child)
+	return child, nil
+}
+
+// Managed reports if the transaction has been opened with Tx instead of UnmanagedTx
+func (t *Transaction) Managed() bool {
+	return t.managed
+}
+
+// Options returns the options the transaction has been opened with
+func (t *Transaction) Options() rstorage.TxOptions {
+	return t.options
+}
+
+// Deadline returns the deadline the transaction has been opened with, if any
+func (t *Transaction) Deadline() (time.Time, bool) {
+	return t.deadline, !t.deadline.IsZero()
+}
+
+// Outcome returns the current state of the transaction
+func (t *Transaction) Outcome() Outcome {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.outcome
+}
+
+// Children returns the nested transactions opened with Begin
+func (t *Transaction) Children() []*Transaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*Transaction(nil), t.children...)
+}
+
+// Storage is a fake storage.Context recording every opened transaction
+type Storage struct {
+	mu        sync.Mutex
+	open      bool
+	txs       []*Transaction
+	txErr     error
+	commitErr error
+}
+
+// NewStorage creates an opened fake storage context
+func NewStorage() *Storage {
+	return &Storage{open: true}
+}
+
+func (s *Storage) ConnStr() string {
+	return "apitest://memory"
+}
+
+func (s *Storage) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.open
+}
+
+func (s *Storage) Open() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.open = true
+	return nil
+}
+
+func (s *Storage) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.open = false
+}
+
+func (s *Storage) Tx() (storage.Transaction, error) {
+	return s.begin(context.Background(), true, rstorage.TxOptions{})
+}
+
+func (s *Storage) UnmanagedTx() (storage.Transaction, error) {
+	return s.begin(context.Background(), false, rstorage.TxOptions{})
+}
+
+// TxWithOptions records options and the deadline of ctx on the opened transaction
+func (s *Storage) TxWithOptions(ctx context.Context, options rstorage.TxOptions) (storage.Transaction, error) {
+	return s.begin(ctx, true, options)
+}
+
+// UnmanagedTxWithOptions records options and the deadline of ctx on the opened transaction
+func (s *Storage) UnmanagedTxWithOptions(ctx context.Context, options rstorage.TxOptions) (storage.Transaction, error) {
+	return s.begin(ctx, false, options)
+}
+
+func (s *Storage) begin(ctx context.Context, managed bool, options rstorage.TxOptions) (storage.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.txErr != nil {
+		return nil, s.txErr
+	}
+
+	deadline, _ := ctx.Deadline()
+	tx := &Transaction{managed: managed, commitErr: s.commitErr, options: options, deadline: deadline}
+	s.txs = append(s.txs, tx)
+	return tx, nil
+}
+
+// FailTx makes every following transaction opening fail with err. Nil restores them
+func (s *Storage) FailTx(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.txErr = err
+}
+
+// FailCommit makes the commit of every following transaction fail with err. Nil restores it
+func (s *Storage) FailCommit(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commitErr = err
+}
+
+// Transactions returns all transactions opened so far, in opening order
+func (s *Storage) Transactions() []*Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Transaction(nil), s.txs...)
+}
+
+// Last returns the last opened transaction, nil if none
+func (s *Storage) Last() *Transaction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.txs) == 0 {
+		return nil
+	}
+	return s.txs[len(s.txs)-1]
+}
+
+// Reset forgets the recorded transactions and the injected failures
+func (s *Storage) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.txs = nil
+	s.txErr = nil
+	s.commitErr = nil
+}
+
+func (s *Storage) assertLast(t testing.TB, expected Outcome) {
+	t.Helper()
+	tx := s.Last()
+	if tx == nil {
+		t.Errorf("expected a %s transaction, none opened", expected)
+		return
+	}
+
+	if tx.Outcome() != expected {
+		t.Errorf("expected last transaction %s, got %s", expected, tx.Outcome())
+	}
+}
+
+// AssertCommitted fails the test if the last opened transaction is not committed
+func (s *Storage) AssertCommitted(t testing.TB) {
+	t.Helper()
+	s.assertLast(t, OutcomeCommitted)
+}
+
+// AssertRolledBack fails the test if the last opened transaction is not rolled back
+func (s *Storage) AssertRolledBack(t testing.TB) {
+	t.Helper()
+	s.assertLast(t, OutcomeRolledBack)
+}
+
+// AssertNoTransaction fails the test if any transaction has been opened
+func (s *Storage) AssertNoTransaction(t testing.TB) {
+	t.Helper()
+	if n := len(s.Transactions()); n > 0 {
+		t.Errorf("expected no transaction, %d opened", n)
+	}
+}
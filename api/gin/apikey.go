@@ -0,0 +1,204 @@
+package gin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/rem/tenant"
+)
+
+// ErrApiKeyNotFound is returned by ApiKeyStore when no key matches the hash
+var ErrApiKeyNotFound = errors.New("API key not found")
+
+// ApiKey is the identity bound to an API key
+type ApiKey struct {
+	TenantID string
+	UserID   string
+	Scopes   []string
+
+	// Zero means the key never expires
+	ExpiresAt time.Time
+	Revoked   bool
+	LastUsed  time.Time
+}
+
+// ApiKeyStore persists API keys by their hash. The plain key is never stored
+type ApiKeyStore interface {
+	// Find returns the key matching hash or ErrApiKeyNotFound
+	Find(hash string) (ApiKey, error)
+
+	// Touch records the last time the key matching hash has been used
+	Touch(hash string, usedAt time.Time) error
+}
+
+// HashApiKey computes the hash used to store and look up a plain API key
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ApiKeyConfig defines where the API key is read from and how it is resolved
+type ApiKeyConfig struct {
+	// Store resolving the key hashes. Mandatory
+	Store ApiKeyStore
+
+	// Request header carrying the key. Default: X-Api-Key
+	Header string
+
+	// Query parameter carrying the key when the header is missing. Empty disables it
+	QueryParam string
+}
+
+// MemoryApiKeyStore is an in memory ApiKeyStore, safe for concurrent use
+type MemoryApiKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]ApiKey
+}
+
+// NewMemoryApiKeyStore creates an empty in memory store
+func NewMemoryApiKeyStore() *MemoryApiKeyStore {
+	return &MemoryApiKeyStore{keys: make(map[string]ApiKey)}
+}
+
+// Add stores the identity of a plain API key
+func (s *MemoryApiKeyStore) Add(key string, apiKey ApiKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[HashApiKey(key)] = apiKey
+}
+
+// Revoke marks a plain API key as revoked
+func (s *MemoryApiKeyStore) Revoke(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hash := HashApiKey(key)
+	apiKey, exist := s.keys[hash]
+	if !exist {
+		return ErrApiKeyNotFound
+	}
+
+	apiKey.Revoked = true
+	s.keys[hash] = apiKey
+	return nil
+}
+
+func (s *MemoryApiKeyStore) Find(hash string) (ApiKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	apiKey, exist := s.keys[hash]
+	if !exist {
+		return ApiKey{}, ErrApiKeyNotFound
+	}
+	return apiKey, nil
+}
+
+func (s *MemoryApiKeyStore) Touch(hash string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	apiKey, exist := s.keys[hash]
+	if !exist {
+		return ErrApiKeyNotFound
+	}
+
+	apiKey.LastUsed = usedAt
+	s.keys[hash] = apiKey
+	return nil
+}
+
+type apiKeyHandler struct {
+	config ApiKeyConfig
+	log    logging.Logger
+}
+
+func newApiKeyHandler(config ApiKeyConfig, log logging.Logger) apiKeyHandler {
+	if config.Store == nil {
+		panic("API key authentication needs an ApiKeyStore")
+	}
+
+	if config.Header == "" {
+		config.Header = "X-Api-Key"
+	}
+
+	return apiKeyHandler{config: config, log: log}
+}
+
+func (g apiKeyHandler) reject(ctx *gin.Context, logCtx logging.Context, devMsg string) {
+	g.log.Error(logCtx, "Rejected request caused by invalid API key. %s", devMsg)
+
+	abortWithError(ctx, http.StatusUnauthorized, api.Model{
+		Error: api.ErrorModel{
+			Code:   api.ApiErrorAuthFailed,
+			Msg:    "Failed to get user information",
+			DevMsg: devMsg,
+			CorrId: logCtx.CorrID(),
+		},
+	})
+}
+
+func (g apiKeyHandler) createTenantFromApiKey(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	key := ctx.GetHeader(g.config.Header)
+	if key == "" && g.config.QueryParam != "" {
+		key = ctx.Query(g.config.QueryParam)
+	}
+
+	if key == "" {
+		g.reject(ctx, logCtx, "Missing API key")
+		return
+	}
+
+	hash := HashApiKey(key)
+	apiKey, err := g.config.Store.Find(hash)
+	if errors.Is(err, ErrApiKeyNotFound) {
+		g.reject(ctx, logCtx, "Unknown API key")
+		return
+	}
+
+	if err != nil {
+		g.log.Error(logCtx, "Failed to look up API key. %v", err)
+
+		abortWithError(ctx, http.StatusInternalServerError, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Failed to get user information",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	now := time.Now()
+
+	if apiKey.Revoked {
+		g.reject(ctx, logCtx, "Revoked API key")
+		return
+	}
+
+	if !apiKey.ExpiresAt.IsZero() && now.After(apiKey.ExpiresAt) {
+		g.reject(ctx, logCtx, "Expired API key")
+		return
+	}
+
+	if err := g.config.Store.Touch(hash, now); err != nil {
+		g.log.Warn(logCtx, "Failed to record API key usage. %v", err)
+	}
+
+	ctx.Set(api.TenantKey, tenant.New(apiKey.TenantID, apiKey.UserID))
+	ctx.Set(ScopesKey, apiKey.Scopes)
+	ctx.Next()
+}
@@ -0,0 +1,180 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/tenant"
+)
+
+// ScopesKey stores in the gin context the []string of scopes granted to the caller
+// by the authentication stage. Authorization reads them to check the required scopes
+const ScopesKey = "_rem_gin_scopes_key"
+
+// RoleProvider resolves the roles of the caller identified by the tenant context
+type RoleProvider interface {
+	Roles(logCtx logging.Context, t tenant.Context) ([]string, error)
+}
+
+// RoleProviderFunc adapts a function to the RoleProvider interface
+type RoleProviderFunc func(logCtx logging.Context, t tenant.Context) ([]string, error)
+
+func (f RoleProviderFunc) Roles(logCtx logging.Context, t tenant.Context) ([]string, error) {
+	return f(logCtx, t)
+}
+
+// AuthorizationConfig declares who may call a route. All the declared checks must pass
+type AuthorizationConfig struct {
+	// The caller needs at least one of these roles
+	Roles []string
+
+	// The caller needs all of these scopes
+	Scopes []string
+
+	// Inline policy expression, see Policy
+	Policy string
+
+	// Name of a policy of Policies
+	PolicyName string
+
+	// Policies loaded with LoadPolicies or NewPolicySet
+	Policies *PolicySet
+
+	// Resolves the caller roles. Required when Roles is set or a policy uses has_role.
+	// Scopes are granted by the authentication stage, see ScopesKey
+	Provider RoleProvider
+}
+
+type authorizationHandler struct {
+	config AuthorizationConfig
+	policy *Policy
+	log    logging.Logger
+}
+
+// newAuthorizationHandler compiles the configured policy. Invalid policies panic,
+// since they are programming errors detected at startup
+func newAuthorizationHandler(config AuthorizationConfig, log logging.Logger) authorizationHandler {
+	handler := authorizationHandler{config: config, log: log}
+
+	if config.Policy != "" {
+		policy, err := CompilePolicy(config.Policy)
+		if err != nil {
+			panic(err.Error())
+		}
+		handler.policy = policy
+	} else if config.PolicyName != "" {
+		if config.Policies == nil {
+			panic(fmt.Sprintf("Policy %s requested without a policy set", config.PolicyName))
+		}
+
+		policy, exist := config.Policies.Get(config.PolicyName)
+		if !exist {
+			panic(fmt.Sprintf("Unknown policy %s", config.PolicyName))
+		}
+		handler.policy = policy
+	}
+
+	if len(config.Roles) > 0 && config.Provider == nil {
+		panic("Authorization by roles needs a role provider")
+	}
+
+	if handler.policy != nil && config.Provider == nil && handler.policy.Uses("has_role") {
+		panic(fmt.Sprintf("Policy %s uses has_role without a role provider", handler.policy))
+	}
+
+	return handler
+}
+
+func (g authorizationHandler) deny(ctx *gin.Context, logCtx logging.Context, devMsg string) {
+	g.log.Warn(logCtx, "Access denied. %s", devMsg)
+
+	abortWithError(ctx, http.StatusForbidden, api.Model{
+		Error: api.ErrorModel{
+			Code:   api.ApiErrorAuthFailed,
+			Msg:    "Operation not allowed",
+			DevMsg: devMsg,
+			CorrId: logCtx.CorrID(),
+		},
+	})
+}
+
+func (g authorizationHandler) authorize(ctx *gin.Context) {
+	// logging and tenant keys are always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	iTenantCtx, _ := ctx.Get(api.TenantKey)
+	tenantCtx := iTenantCtx.(tenant.Context)
+
+	input := PolicyInput{
+		Tenant:  tenantCtx.ID(),
+		User:    tenantCtx.UserID(),
+		Params:  make(map[string]string),
+		Headers: make(map[string]string),
+	}
+
+	if scopes, exist := ctx.Get(ScopesKey); exist {
+		input.Scopes = scopes.([]string)
+	}
+
+	if g.config.Provider != nil {
+		roles, err := g.config.Provider.Roles(logCtx, tenantCtx)
+		if err != nil {
+			g.log.Error(logCtx, "Failed to resolve roles of user %s of tenant %s. %v", tenantCtx.UserID(), tenantCtx.ID(), err)
+
+			abortWithError(ctx, http.StatusInternalServerError, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorUnexpected,
+					Msg:    "Failed to get user information",
+					DevMsg: err.Error(),
+					CorrId: logCtx.CorrID(),
+				},
+			})
+			return
+		}
+		input.Roles = roles
+	}
+
+	if len(g.config.Roles) > 0 {
+		granted := false
+		for _, role := range g.config.Roles {
+			if contains(input.Roles, role) {
+				granted = true
+				break
+			}
+		}
+
+		if !granted {
+			g.deny(ctx, logCtx, fmt.Sprintf("User %s needs one of the roles %s", input.User, strings.Join(g.config.Roles, ", ")))
+			return
+		}
+	}
+
+	for _, scope := range g.config.Scopes {
+		if !contains(input.Scopes, scope) {
+			g.deny(ctx, logCtx, fmt.Sprintf("User %s misses the scope %s", input.User, scope))
+			return
+		}
+	}
+
+	if g.policy != nil {
+		for _, p := range ctx.Params {
+			input.Params[p.Key] = p.Value
+		}
+
+		for name := range ctx.Request.Header {
+			input.Headers[name] = ctx.Request.Header.Get(name)
+		}
+
+		if !g.policy.Allow(input) {
+			g.deny(ctx, logCtx, fmt.Sprintf("User %s rejected by policy %s", input.User, g.policy))
+			return
+		}
+	}
+
+	ctx.Next()
+}
@@ -0,0 +1,285 @@
+package gin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/storage"
+)
+
+// BatchConfig defines the batch route
+type BatchConfig struct {
+	// Storage opening the transaction shared by all the sub-requests
+	Storage storage.Context
+
+	// Maximum number of sub-requests accepted in a single call. Default: 100
+	MaxItems int
+}
+
+// BatchItem is a sub-request of a batch call
+type BatchItem struct {
+	Method  string            `json:"method" binding:"required"`
+	Path    string            `json:"path" binding:"required"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// BatchItemResult is the outcome of a sub-request. Result holds its api.Model envelope,
+// it is empty for the sub-requests skipped after a failure
+type BatchItemResult struct {
+	Status int             `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// batchTxKey marks the requests running inside a batch, carrying the shared transaction
+type batchTxKey struct{}
+
+// batchTransaction returns the transaction shared by the batch the request belongs to
+func batchTransaction(ctx *gin.Context) (storage.Transaction, bool) {
+	tx, ok := ctx.Request.Context().Value(batchTxKey{}).(storage.Transaction)
+	return tx, ok
+}
+
+// rejectInBatch aborts the batch sub-requests targeting a route that cannot share the batch
+// transaction: unmanaged and custom transactions commit on their own and jobs run after the
+// batch completed, so their changes would survive a rollback of the batch
+func rejectInBatch(ctx *gin.Context) {
+	if _, ok := batchTransaction(ctx); !ok {
+		ctx.Next()
+		return
+	}
+
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	abortWithError(ctx, http.StatusBadRequest, api.Model{
+		Error: api.ErrorModel{
+			Code:   api.ApiErrorUnexpected,
+			Msg:    "Invalid batch item",
+			DevMsg: fmt.Sprintf("Route %s %s cannot run in the transaction of a batch", ctx.Request.Method, ctx.FullPath()),
+			CorrId: logCtx.CorrID(),
+		},
+	})
+}
+
+// batchSkippedHeaders are the caller headers not copied into the sub-requests, in canonical form.
+// Items must answer plain JSON envelopes and their bodies are neither encoded nor signed
+var batchSkippedHeaders = map[string]struct{}{
+	"Content-Length":    {},
+	"Content-Type":      {},
+	"Content-Encoding":  {},
+	"Accept-Encoding":   {},
+	"Transfer-Encoding": {},
+	HeaderSignature:     {},
+	HeaderSignatureKey:  {},
+	HeaderTimestamp:     {},
+	HeaderNonce:         {},
+}
+
+type batchHandler struct {
+	config BatchConfig
+	path   string
+	engine *gin.Engine
+	log    logging.Logger
+}
+
+func (g batchHandler) runItem(ctx *gin.Context, logCtx logging.Context, tx storage.Transaction, item BatchItem) (BatchItemResult, bool) {
+	req, err := http.NewRequest(item.Method, item.Path, bytes.NewReader(item.Body))
+	if err != nil {
+		body, _ := json.Marshal(api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Invalid batch item",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return BatchItemResult{Status: http.StatusBadRequest, Result: body}, false
+	}
+
+	// sub-requests inherit the caller identity unless overridden by the item. The headers
+	// describing the outer payload and its signature do not apply to the items
+	for name, values := range ctx.Request.Header {
+		if _, skip := batchSkippedHeaders[name]; !skip {
+			req.Header[name] = values
+		}
+	}
+
+	req.Header.Set("X-Correlation-ID", logCtx.CorrID())
+	if len(item.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for name, value := range item.Headers {
+		name = http.CanonicalHeaderKey(name)
+		if _, skip := batchSkippedHeaders[name]; !skip || name == "Content-Type" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	req = req.WithContext(context.WithValue(ctx.Request.Context(), batchTxKey{}, tx))
+	recorder := httptest.NewRecorder()
+	g.engine.ServeHTTP(recorder, req)
+
+	result := BatchItemResult{Status: recorder.Code}
+
+	// routes outside of the rem pipeline (e.g. not found) may not answer with an envelope
+	var envelope api.Model
+	if err := json.Unmarshal(recorder.Body.Bytes(), &envelope); err != nil {
+		return result, false
+	}
+
+	result.Result = recorder.Body.Bytes()
+
+	return result, recorder.Code < 300 && envelope.Error.Code == api.ApiErrorNoError
+}
+
+// isBatchPath reports if an item targets the batch route itself. Nested batches reaching
+// the route anyway, e.g. through redirects, are rejected by the batch transaction marker
+func (g batchHandler) isBatchPath(itemPath string) bool {
+	parsed, err := url.Parse(itemPath)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(path.Clean("/"+parsed.Path), path.Clean(g.path))
+}
+
+func (g batchHandler) handleBatch(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	var items []BatchItem
+	if err := ctx.ShouldBindJSON(&items); err != nil {
+		abortWithError(ctx, http.StatusBadRequest, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API needs a valid payload",
+				DevMsg: fmt.Sprintf("Failed to transform batch items from JSON. %v", err),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	_, nested := batchTransaction(ctx)
+
+	for _, item := range items {
+		if nested || g.isBatchPath(item.Path) {
+			abortWithError(ctx, http.StatusBadRequest, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorUnexpected,
+					Msg:    "Invalid batch item",
+					DevMsg: "Batch calls cannot be nested",
+					CorrId: logCtx.CorrID(),
+				},
+			})
+			return
+		}
+	}
+
+	if len(items) == 0 || len(items) > g.config.MaxItems {
+		abortWithError(ctx, http.StatusBadRequest, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API needs a valid payload",
+				DevMsg: fmt.Sprintf("Batch must contain between 1 and %d items, got %d", g.config.MaxItems, len(items)),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	tx, err := g.config.Storage.Tx()
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Failed to open new managed transaction",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	results := make([]BatchItemResult, len(items))
+	failed := -1
+
+	for i, item := range items {
+		if failed >= 0 {
+			results[i] = BatchItemResult{Status: http.StatusFailedDependency}
+			continue
+		}
+
+		result, ok := g.runItem(ctx, logCtx, tx, item)
+		results[i] = result
+
+		if !ok {
+			g.log.Warn(logCtx, "Batch item %d (%s %s) failed with status %d, rolling back", i, item.Method, item.Path, result.Status)
+			failed = i
+		}
+	}
+
+	if failed >= 0 {
+		if err := tx.Rollback(); err != nil {
+			g.log.Error(logCtx, "Failed to rollback batch transaction. %v", err)
+		}
+
+		var itemEnvelope api.Model
+		json.Unmarshal(results[failed].Result, &itemEnvelope)
+
+		code := itemEnvelope.Error.Code
+		if code == api.ApiErrorNoError {
+			code = api.ApiErrorUnexpected
+		}
+
+		status := results[failed].Status
+		if status < http.StatusBadRequest {
+			status = http.StatusInternalServerError
+		}
+
+		abortWithError(ctx, status, api.Model{
+			Error: api.ErrorModel{
+				Code:   code,
+				Msg:    "Batch rolled back",
+				DevMsg: fmt.Sprintf("Batch item %d failed", failed),
+				CorrId: logCtx.CorrID(),
+			},
+			Data: results,
+		})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Failed to commit changes",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, api.Model{
+		Error: api.ErrorModel{
+			Code:   api.ApiErrorNoError,
+			CorrId: logCtx.CorrID(),
+		},
+		Data: results,
+	})
+}
@@ -0,0 +1,228 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/runtime"
+)
+
+// BreakerState is the state of a CircuitBreaker
+type BreakerState int
+
+const (
+	// BreakerClosed opens the transactions normally
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects the requests without opening the transactions
+	BreakerOpen
+
+	// BreakerHalfOpen lets a single probe request through to check if the storage recovered
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// CircuitBreakerConfig defines when a CircuitBreaker trips
+type CircuitBreakerConfig struct {
+	// Name of the breaker in logs and health checks. Default: storage
+	Name string
+
+	// Consecutive failures opening transactions tripping the breaker. Default: 5
+	Threshold int
+
+	// Time the breaker stays open before probing the storage again. Default: 30 seconds
+	Cooldown time.Duration
+}
+
+// CircuitBreaker stops opening transactions on a storage failing consecutively.
+// Share one breaker among the routes of the same storage.Context
+type CircuitBreaker struct {
+	config   CircuitBreakerConfig
+	log      logging.Logger
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// BreakerStatus reports the state of a CircuitBreaker
+type BreakerStatus struct {
+	Name     string     `json:"name"`
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"openedAt,omitempty"`
+}
+
+// NewCircuitBreaker creates a closed breaker
+func NewCircuitBreaker(log logging.Logger, config CircuitBreakerConfig) *CircuitBreaker {
+	if config.Name == "" {
+		config.Name = "storage"
+	}
+
+	if config.Threshold <= 0 {
+		config.Threshold = 5
+	}
+
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+
+	return &CircuitBreaker{config: config, log: log}
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.config.Cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// Status returns the state of the breaker for health checks
+func (b *CircuitBreaker) Status() BreakerStatus {
+	state := b.State()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{Name: b.config.Name, State: state.String(), Failures: b.failures}
+	if state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// allow reports if a transaction can be opened, otherwise the time left before the next probe
+func (b *CircuitBreaker) allow(logCtx logging.Context) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		left := b.config.Cooldown - time.Since(b.openedAt)
+		if left > 0 {
+			return false, left
+		}
+
+		b.state = BreakerHalfOpen
+		b.probing = true
+		b.log.Info(logCtx, "Circuit breaker %s half-open, probing the storage", b.config.Name)
+		return true, 0
+
+	case BreakerHalfOpen:
+		// a single probe at a time, the others wait for its outcome
+		if b.probing {
+			return false, time.Second
+		}
+
+		b.probing = true
+		return true, 0
+	}
+
+	return true, 0
+}
+
+func (b *CircuitBreaker) success(logCtx logging.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerClosed {
+		b.log.Info(logCtx, "Circuit breaker %s closed, the storage recovered", b.config.Name)
+	}
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// abandon releases the probe of an attempt that says nothing about the storage health
+func (b *CircuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *CircuitBreaker) failure(logCtx logging.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.config.Threshold) {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+		b.log.Warn(logCtx, "Circuit breaker %s open for %s after %d consecutive failures. %v",
+			b.config.Name, b.config.Cooldown, b.failures, err)
+	}
+}
+
+// reject answers 503 to the requests arriving while the breaker is open
+func (b *CircuitBreaker) reject(ctx *gin.Context, logCtx logging.Context, retryAfter time.Duration) {
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	ctx.Header("Retry-After", strconv.Itoa(seconds))
+
+	abortWithError(ctx, http.StatusServiceUnavailable, api.Model{
+		Error: api.ErrorModel{
+			Code:   ApiErrorUnavailable,
+			Msg:    "Service temporarily unavailable",
+			DevMsg: fmt.Sprintf("Circuit breaker %s is %s", b.config.Name, b.State()),
+			CorrId: logCtx.CorrID(),
+		},
+	})
+}
+
+// BreakerHealthService is a service answering with the status of the breakers, failing
+// with 503 while any of them is not closed, e.g.
+//
+//	admin.AddRoute(http.MethodGet, "/health", config, gin.BreakerHealthService(dbBreaker))
+func BreakerHealthService(breakers ...*CircuitBreaker) api.Service {
+	return func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		var statuses []BreakerStatus
+		var unhealthy []string
+
+		for _, breaker := range breakers {
+			status := breaker.Status()
+			statuses = append(statuses, status)
+
+			if status.State != BreakerClosed.String() {
+				unhealthy = append(unhealthy, fmt.Sprintf("%s %s", status.Name, status.State))
+			}
+		}
+
+		if len(unhealthy) > 0 {
+			return serviceOutput{
+				status: ApiErrorUnavailable,
+				msg:    "Service unhealthy",
+				err:    fmt.Errorf("Circuit breakers not closed: %s", strings.Join(unhealthy, ", ")),
+				data:   statuses,
+			}
+		}
+
+		return serviceOutput{status: api.ApiErrorNoError, data: statuses}
+	}
+}
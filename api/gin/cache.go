@@ -0,0 +1,274 @@
+package gin
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/tenant"
+)
+
+// CacheEntry is a cached response. Data is the JSON response model, the envelope is
+// rebuilt for every request so that it carries the caller correlation ID
+type CacheEntry struct {
+	Data      json.RawMessage
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// cachedModel decodes the envelope of a response keeping its model as JSON
+type cachedModel struct {
+	Data json.RawMessage `json:"data"`
+}
+
+// Cache is the backend storing the cached responses
+type Cache interface {
+	// Get returns the entry of key, if present and not expired
+	Get(key string) (CacheEntry, bool)
+
+	// Set stores entry with key
+	Set(key string, entry CacheEntry)
+
+	// DeletePrefix removes all the entries whose key starts with prefix
+	DeletePrefix(prefix string)
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+}
+
+// LRUCache is an in memory Cache evicting the least recently used entries, safe for concurrent use
+type LRUCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+// NewLRUCache creates an LRU cache holding up to capacity entries
+func NewLRUCache(capacity int) *LRUCache {
+	return &LRUCache{capacity: capacity, items: make(map[string]*list.Element), order: list.New()}
+}
+
+func (c *LRUCache) Get(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exist := c.items[key]
+	if !exist {
+		return CacheEntry{}, false
+	}
+
+	item := element.Value.(*lruItem)
+	if time.Now().After(item.entry.ExpiresAt) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		return CacheEntry{}, false
+	}
+
+	c.order.MoveToFront(element)
+	return item.entry, true
+}
+
+func (c *LRUCache) Set(key string, entry CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exist := c.items[key]; exist {
+		element.Value.(*lruItem).entry = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruItem{key: key, entry: entry})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+}
+
+func (c *LRUCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(element)
+			delete(c.items, key)
+		}
+	}
+}
+
+// ResponseCache caches the responses of the routes configured with ConfigBuilder.Cache.
+// Entries are keyed by tenant, route, path parameters, user and the selected query parameters
+// and headers. Services invalidate them after changing the cached data
+type ResponseCache struct {
+	backend Cache
+}
+
+// NewResponseCache creates a response cache on backend. Nil backend uses an LRU cache of 1000 entries
+func NewResponseCache(backend Cache) *ResponseCache {
+	if backend == nil {
+		backend = NewLRUCache(1000)
+	}
+
+	return &ResponseCache{backend: backend}
+}
+
+// cacheKey joins the key parts. Every part is terminated, so that a prefix never
+// matches a longer value of its last part
+func cacheKey(parts ...string) string {
+	var key strings.Builder
+	for _, part := range parts {
+		key.WriteString(part)
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+// Invalidate removes the cached responses of route (as declared, e.g. /items/:id) for
+// tenantID. Params restrict the invalidation to the given leading path parameter values.
+// An empty route invalidates all the routes of the tenant
+func (c *ResponseCache) Invalidate(tenantID string, route string, params ...string) {
+	if route == "" {
+		c.backend.DeletePrefix(cacheKey(tenantID))
+		return
+	}
+
+	c.backend.DeletePrefix(cacheKey(append([]string{tenantID, route}, params...)...))
+}
+
+// CacheConfig defines the response cache of a GET route
+type CacheConfig struct {
+	// Cache storing the responses
+	Cache *ResponseCache
+
+	// Lifetime of the cached responses. Default: 1 minute
+	TTL time.Duration
+
+	// Query parameters changing the response, part of the cache key
+	Query []string
+
+	// Request headers changing the response, part of the cache key
+	Headers []string
+
+	// Shared declares that the responses do not depend on the calling user, so that all the
+	// users of a tenant share the cached entries. Otherwise every user has its own entries.
+	// Routes resolving no tenant share the entries among all the callers and must set it
+	Shared bool
+}
+
+type cacheHandler struct {
+	config CacheConfig
+	log    logging.Logger
+}
+
+func newCacheHandler(config CacheConfig, log logging.Logger) cacheHandler {
+	if config.Cache == nil {
+		panic("Response caching needs a ResponseCache")
+	}
+
+	if config.TTL <= 0 {
+		config.TTL = time.Minute
+	}
+
+	return cacheHandler{config: config, log: log}
+}
+
+func (g cacheHandler) key(ctx *gin.Context, tenantCtx tenant.Context) string {
+	parts := []string{tenantCtx.ID(), ctx.FullPath()}
+
+	for _, param := range ctx.Params {
+		parts = append(parts, param.Value)
+	}
+
+	// after the path parameters, so that Invalidate matches the entries of all the users
+	if !g.config.Shared {
+		parts = append(parts, "u:"+tenantCtx.UserID())
+	}
+
+	for _, name := range g.config.Query {
+		parts = append(parts, "q:"+name+"="+strings.Join(ctx.Request.URL.Query()[name], ","))
+	}
+
+	for _, name := range g.config.Headers {
+		parts = append(parts, "h:"+name+"="+strings.Join(ctx.Request.Header.Values(name), ","))
+	}
+
+	return cacheKey(parts...)
+}
+
+func (g cacheHandler) handleCache(ctx *gin.Context) {
+	// logging and tenant keys are always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	iTenantCtx, _ := ctx.Get(api.TenantKey)
+	tenantCtx := iTenantCtx.(tenant.Context)
+
+	requestControl := strings.ToLower(ctx.GetHeader("Cache-Control"))
+	noStore := strings.Contains(requestControl, "no-store")
+	noCache := noStore || strings.Contains(requestControl, "no-cache") ||
+		strings.Contains(requestControl, "max-age=0")
+
+	key := g.key(ctx, tenantCtx)
+
+	if !noCache {
+		if entry, hit := g.config.Cache.backend.Get(key); hit {
+			g.log.Debug(logCtx, "Response of %s served from cache", ctx.Request.URL.Path)
+
+			remaining := int(time.Until(entry.ExpiresAt).Seconds())
+			ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", remaining))
+			ctx.Header("Age", strconv.Itoa(int(time.Since(entry.StoredAt).Seconds())))
+			ctx.Header("X-Cache", "HIT")
+			ctx.JSON(http.StatusOK, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorNoError,
+					CorrId: logCtx.CorrID(),
+				},
+				Data: entry.Data,
+			})
+			ctx.Abort()
+			return
+		}
+	}
+
+	writer := newBufferedWriter(ctx.Writer)
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = writer.ResponseWriter
+
+	body := writer.Bytes()
+	header := ctx.Writer.Header()
+
+	if writer.Status() == http.StatusOK {
+		var model cachedModel
+		if !noStore && !strings.Contains(strings.ToLower(header.Get("Cache-Control")), "no-store") &&
+			json.Unmarshal(body, &model) == nil {
+			now := time.Now()
+			g.config.Cache.backend.Set(key, CacheEntry{
+				Data:      model.Data,
+				StoredAt:  now,
+				ExpiresAt: now.Add(g.config.TTL),
+			})
+		}
+
+		if header.Get("Cache-Control") == "" {
+			ctx.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(g.config.TTL.Seconds())))
+		}
+	}
+
+	ctx.Header("X-Cache", "MISS")
+	writer.flush(body)
+}
@@ -0,0 +1,314 @@
+package gin
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// EncodingGzip identifies the gzip content encoding
+	EncodingGzip = "gzip"
+
+	// EncodingBrotli identifies the brotli content encoding
+	EncodingBrotli = "br"
+
+	// EncodingZstd identifies the zstandard content encoding
+	EncodingZstd = "zstd"
+)
+
+// ErrBodyTooLarge is returned while reading a request body exceeding the configured limit
+var ErrBodyTooLarge = errors.New("Request body exceeds the maximum allowed size")
+
+// CompressionConfig defines how responses are compressed and how compressed request bodies are accepted
+type CompressionConfig struct {
+	// Response encodings in server preference order. Default: br, zstd, gzip
+	Encodings []string
+
+	// Compression level passed to gzip and brotli writers. Zero uses each library default
+	Level int
+
+	// Responses smaller than MinSize bytes are sent uncompressed. Default: 1024
+	MinSize int
+
+	// Media types eligible for compression. Default: application/json
+	ContentTypes []string
+
+	// Maximum size of a decompressed request body. Default: 10MB
+	MaxDecompressedSize int64
+}
+
+// limitedReadCloser fails with ErrBodyTooLarge when more than limit bytes are read
+type limitedReadCloser struct {
+	reader io.Reader
+	closer io.Closer
+	limit  int64
+}
+
+func (r *limitedReadCloser) Read(p []byte) (int, error) {
+	if r.limit <= 0 {
+		// probe one byte to distinguish between an exact fit and an overflow
+		var probe [1]byte
+		if n, _ := r.reader.Read(probe[:]); n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+
+	if int64(len(p)) > r.limit {
+		p = p[:r.limit]
+	}
+
+	n, err := r.reader.Read(p)
+	r.limit -= int64(n)
+	return n, err
+}
+
+func (r *limitedReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+type compressionHandler struct {
+	config CompressionConfig
+	log    logging.Logger
+	zstd   *zstd.Encoder
+}
+
+func newCompressionHandler(config CompressionConfig, log logging.Logger) compressionHandler {
+	if len(config.Encodings) == 0 {
+		config.Encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	}
+
+	if config.MinSize == 0 {
+		config.MinSize = 1024
+	}
+
+	if len(config.ContentTypes) == 0 {
+		config.ContentTypes = []string{"application/json"}
+	}
+
+	if config.MaxDecompressedSize == 0 {
+		config.MaxDecompressedSize = 10 << 20
+	}
+
+	// the encoder is safe for concurrent use through EncodeAll
+	encoder, _ := zstd.NewWriter(nil)
+
+	return compressionHandler{config: config, log: log, zstd: encoder}
+}
+
+// negotiate selects the encoding with the highest client weight, using the server
+// preference order to break ties. An empty string means no compression.
+func (g compressionHandler) negotiate(acceptEncoding string) string {
+	weights := make(map[string]float64)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+
+		weight := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if q, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					weight = q
+				}
+			}
+		}
+
+		weights[name] = weight
+	}
+
+	selected := ""
+	selectedWeight := 0.0
+
+	for _, encoding := range g.config.Encodings {
+		weight, exist := weights[encoding]
+		if !exist {
+			weight, exist = weights["*"]
+		}
+
+		if exist && weight > selectedWeight {
+			selected = encoding
+			selectedWeight = weight
+		}
+	}
+
+	return selected
+}
+
+func (g compressionHandler) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return containsFold(g.config.ContentTypes, mediaType)
+}
+
+func (g compressionHandler) compress(encoding string, data []byte) ([]byte, error) {
+	if encoding == EncodingZstd {
+		return g.zstd.EncodeAll(data, nil), nil
+	}
+
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+
+	switch encoding {
+	case EncodingGzip:
+		level := gzip.DefaultCompression
+		if g.config.Level != 0 {
+			level = g.config.Level
+		}
+
+		w, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, err
+		}
+		writer = w
+
+	case EncodingBrotli:
+		level := brotli.DefaultCompression
+		if g.config.Level != 0 {
+			level = g.config.Level
+		}
+		writer = brotli.NewWriterLevel(&buf, level)
+
+	default:
+		return nil, fmt.Errorf("Unsupported encoding %s", encoding)
+	}
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (g compressionHandler) decompressBody(ctx *gin.Context, logCtx logging.Context) bool {
+	encoding := ctx.GetHeader("Content-Encoding")
+	if encoding == "" || strings.EqualFold(encoding, "identity") {
+		return true
+	}
+
+	if !strings.EqualFold(encoding, EncodingGzip) {
+		abortWithError(ctx, http.StatusUnsupportedMediaType, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API does not support the payload encoding",
+				DevMsg: fmt.Sprintf("Unsupported Content-Encoding %s", encoding),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return false
+	}
+
+	reader, err := gzip.NewReader(ctx.Request.Body)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API needs a valid payload",
+				DevMsg: fmt.Sprintf("Failed to decompress gzip payload. %v", err),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return false
+	}
+
+	// decompress upfront, so that an oversized body is rejected here and not as an invalid
+	// payload by the stage reading it
+	body, err := ioutil.ReadAll(&limitedReadCloser{reader: reader, closer: ctx.Request.Body, limit: g.config.MaxDecompressedSize})
+	if errors.Is(err, ErrBodyTooLarge) {
+		g.log.Warn(logCtx, "Rejected request body decompressing to more than %d bytes", g.config.MaxDecompressedSize)
+
+		abortWithError(ctx, http.StatusRequestEntityTooLarge, api.Model{
+			Error: api.ErrorModel{
+				Code:   ApiErrorPayloadTooLarge,
+				Msg:    "Payload too large",
+				DevMsg: fmt.Sprintf("Decompressed request body must not exceed %d bytes", g.config.MaxDecompressedSize),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return false
+	}
+
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API needs a valid payload",
+				DevMsg: fmt.Sprintf("Failed to decompress gzip payload. %v", err),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return false
+	}
+
+	ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	ctx.Request.Header.Del("Content-Encoding")
+	ctx.Request.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	ctx.Request.ContentLength = int64(len(body))
+	return true
+}
+
+func (g compressionHandler) handleCompression(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	if !g.decompressBody(ctx, logCtx) {
+		return
+	}
+
+	encoding := g.negotiate(ctx.GetHeader("Accept-Encoding"))
+	if encoding == "" {
+		ctx.Next()
+		return
+	}
+
+	writer := newBufferedWriter(ctx.Writer)
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = writer.ResponseWriter
+
+	ctx.Writer.Header().Add("Vary", "Accept-Encoding")
+
+	body := writer.Bytes()
+	if len(body) < g.config.MinSize || ctx.Writer.Header().Get("Content-Encoding") != "" ||
+		!g.compressible(ctx.Writer.Header().Get("Content-Type")) {
+		writer.flush(body)
+		return
+	}
+
+	compressed, err := g.compress(encoding, body)
+	if err != nil {
+		g.log.Warn(logCtx, "Failed to compress response with %s, sending it uncompressed. %v", encoding, err)
+		writer.flush(body)
+		return
+	}
+
+	ctx.Header("Content-Encoding", encoding)
+	ctx.Writer.Header().Del("Content-Length")
+	writer.flush(compressed)
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/hellcats88/abstracte/api"
 	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/storage"
+	rstorage "github.com/hellcats88/rem/storage"
 )
 
 type ginConfig struct {
@@ -17,24 +19,75 @@ type ginConfig struct {
 	queryParams gin.HandlerFunc
 	beforeRun   []gin.HandlerFunc
 	afterRun    []gin.HandlerFunc
+	cors        *CorsConfig
+	compression gin.HandlerFunc
+	authorize   gin.HandlerFunc
+	signature   gin.HandlerFunc
+	jws         gin.HandlerFunc
+	maxBody     int64
+	deprecation gin.HandlerFunc
+	async       *JobRunner
+	jobTx       transactionHandler
+	cache       gin.HandlerFunc
+	cacheShared bool
+	features    []string
+	retry       *retryHandler
+	info        RouteInfo
 }
 
 func (g ginConfig) Valid() bool {
 	return true
 }
 
+// ConfigBuilder extends api.ConfigBuilder with the pipeline stages available only on gin.
+// Generic methods return api.ConfigBuilder, so gin specific options must be set through
+// this interface before chaining generic ones.
+type ConfigBuilder interface {
+	api.ConfigBuilder
+	Cors(CorsConfig) ConfigBuilder
+	Compression(CompressionConfig) ConfigBuilder
+	JsonPatch() ConfigBuilder
+	MergePatch() ConfigBuilder
+	TypedParams(interface{}) ConfigBuilder
+	Authorize(AuthorizationConfig) ConfigBuilder
+	TenantFromApiKey(ApiKeyConfig) ConfigBuilder
+	SignedRequests(HmacConfig) ConfigBuilder
+	SignResponses(JwsConfig) ConfigBuilder
+	MaxBodySize(int64) ConfigBuilder
+	Deprecated(DeprecationConfig) ConfigBuilder
+	Async(*JobRunner) ConfigBuilder
+	Cache(CacheConfig) ConfigBuilder
+	RequireFeature(string) ConfigBuilder
+	CircuitBreaker(*CircuitBreaker) ConfigBuilder
+	Retry(RetryPolicy) ConfigBuilder
+	TxOptions(rstorage.TxOptions) ConfigBuilder
+}
+
 type ginConfigBuilder struct {
-	config ginConfig
-	log    logging.Logger
+	config  ginConfig
+	log     logging.Logger
+	db      storage.Context
+	breaker *CircuitBreaker
+	retry   *RetryPolicy
+	options *rstorage.TxOptions
 }
 
-func NewConfigBuilder(log logging.Logger) api.ConfigBuilder {
+// NewConfigBuilder creates a route configuration builder. Routes built without
+// a storage context cannot use managed or unmanaged transactions
+func NewConfigBuilder(log logging.Logger) ConfigBuilder {
+	return NewConfigBuilderWithStorage(log, nil)
+}
+
+// NewConfigBuilderWithStorage creates a route configuration builder opening transactions from db
+func NewConfigBuilderWithStorage(log logging.Logger, db storage.Context) ConfigBuilder {
 	return &ginConfigBuilder{
 		log: log,
+		db:  db,
 		config: ginConfig{
 			log:    logHandler{}.createLogContext,
 			tx:     transactionHandler{log: log}.createNoTransaction,
 			tenant: tenantHandler{log: log}.createNoTenant,
+			info:   RouteInfo{Kind: RouteKindService, Tenant: RouteModeNone, Tx: RouteModeNone},
 		},
 	}
 }
@@ -52,67 +105,96 @@ func (b *ginConfigBuilder) CustomLog(p api.C) api.ConfigBuilder {
 func (b *ginConfigBuilder) Tenant(p api.ConfigTenant) api.ConfigBuilder {
 	if p == api.ConfigTenantFromHeaders {
 		b.config.tenant = tenantHandler{log: b.log}.createTenantFromHeaders
+		b.config.info.Tenant = RouteModeHeaders
 	}
 	return b
 }
 
 func (b *ginConfigBuilder) CustomTenant(p api.C) api.ConfigBuilder {
 	b.config.tenant = p.Handler.(gin.HandlerFunc)
+	b.config.info.Tenant = RouteModeCustom
 	return b
 }
 
 func (b *ginConfigBuilder) Tx(p api.ConfigTx) api.ConfigBuilder {
+	handler := transactionHandler{log: b.log, db: b.db, breaker: b.breaker, options: b.options}
+
 	if p == api.ConfigTxManaged {
-		b.config.tx = transactionHandler{log: b.log}.createManagedTransaction
-		b.config.commit = transactionHandler{log: b.log}.createCommitTx
+		b.config.tx = handler.createManagedTransaction
+		b.config.commit = handler.createCommitTx
+		b.config.info.Tx = RouteModeManaged
+
+		if b.retry != nil {
+			b.config.retry = newRetryHandler(*b.retry, handler, b.log)
+		}
 	} else if p == api.ConfigTxUnmanaged {
-		b.config.tx = transactionHandler{log: b.log}.createUnmanagedTransaction
+		b.config.tx = handler.createUnmanagedTransaction
+		b.config.info.Tx = RouteModeUnmanaged
 	}
 	return b
 }
 
+// reapplyTx rebuilds the transaction stages after a change of their options
+func (b *ginConfigBuilder) reapplyTx() {
+	switch b.config.info.Tx {
+	case RouteModeManaged:
+		b.Tx(api.ConfigTxManaged)
+	case RouteModeUnmanaged:
+		b.Tx(api.ConfigTxUnmanaged)
+	}
+}
+
 func (b *ginConfigBuilder) CustomTx(p api.C) api.ConfigBuilder {
 	b.config.tx = p.Handler.(gin.HandlerFunc)
+	b.config.info.Tx = RouteModeCustom
 	return b
 }
 
 func (b *ginConfigBuilder) Headers(p interface{}) api.ConfigBuilder {
 	b.config.headers = headersHandler{requestedModel: p, log: b.log}.loadHEaders
+	b.config.info.Headers = typeName(p)
 	return b
 }
 
 func (b *ginConfigBuilder) CustomHeaders(p api.C) api.ConfigBuilder {
 	b.config.headers = p.Handler.(gin.HandlerFunc)
+	b.config.info.Headers = RouteModeCustom
 	return b
 }
 
 func (b *ginConfigBuilder) InputModel(p interface{}) api.ConfigBuilder {
 	b.config.model = modelHandler{requestedModel: p}.getModel
+	b.config.info.InputModel = typeName(p)
 	return b
 }
 
 func (b *ginConfigBuilder) CustomInputModel(p api.C) api.ConfigBuilder {
 	b.config.model = p.Handler.(gin.HandlerFunc)
+	b.config.info.InputModel = RouteModeCustom
 	return b
 }
 
 func (b *ginConfigBuilder) InputParams(name []string) api.ConfigBuilder {
 	b.config.params = inputParamsHandler{requestedInputParams: name}.loadParams
+	b.config.info.InputParams = name
 	return b
 }
 
 func (b *ginConfigBuilder) CustomInputParam(p api.C) api.ConfigBuilder {
 	b.config.params = p.Handler.(gin.HandlerFunc)
+	b.config.info.InputParams = []string{RouteModeCustom}
 	return b
 }
 
 func (b *ginConfigBuilder) QueryParams(p interface{}) api.ConfigBuilder {
 	b.config.queryParams = queryParamsHandler{requestedModel: p, log: b.log}.getQueryParams
+	b.config.info.QueryParams = typeName(p)
 	return b
 }
 
 func (b *ginConfigBuilder) CustomQueryParams(p api.C) api.ConfigBuilder {
 	b.config.queryParams = p.Handler.(gin.HandlerFunc)
+	b.config.info.QueryParams = RouteModeCustom
 	return b
 }
 
@@ -126,6 +208,153 @@ func (b *ginConfigBuilder) CustomAfterRun(p api.C) api.ConfigBuilder {
 	return b
 }
 
+// Cors applies the CORS policy p. A wildcard origin cannot allow credentials, browsers
+// refuse it and echoing any origin instead would share the credentials with every site
+func (b *ginConfigBuilder) Cors(p CorsConfig) ConfigBuilder {
+	if p.AllowCredentials && contains(p.AllowedOrigins, "*") {
+		panic("CORS cannot allow credentials to any origin")
+	}
+
+	b.config.cors = &p
+	return b
+}
+
+func (b *ginConfigBuilder) Compression(p CompressionConfig) ConfigBuilder {
+	b.config.compression = newCompressionHandler(p, b.log).handleCompression
+	return b
+}
+
+// JsonPatch makes the route input model a validated RFC 6902 document, available as Patch
+func (b *ginConfigBuilder) JsonPatch() ConfigBuilder {
+	b.config.model = patchHandler{}.getPatch
+	b.config.info.InputModel = "JsonPatch"
+	return b
+}
+
+// MergePatch makes the route input model a validated RFC 7396 document, available as Patch
+func (b *ginConfigBuilder) MergePatch() ConfigBuilder {
+	b.config.model = patchHandler{mergePatch: true}.getPatch
+	b.config.info.InputModel = "MergePatch"
+	return b
+}
+
+// TypedParams declares the path parameters through the param, enum and pattern tags of
+// the fields of p. Converted values are available from ServiceInput.TypedParams
+func (b *ginConfigBuilder) TypedParams(p interface{}) ConfigBuilder {
+	handler := newTypedParamsHandler(p)
+	b.config.params = handler.loadTypedParams
+
+	b.config.info.TypedParams = typeName(p)
+	b.config.info.InputParams = nil
+	for _, param := range handler.params {
+		b.config.info.InputParams = append(b.config.info.InputParams, param.name)
+	}
+	return b
+}
+
+// Authorize rejects with 403 the callers not satisfying the roles, scopes and policy of p
+func (b *ginConfigBuilder) Authorize(p AuthorizationConfig) ConfigBuilder {
+	b.config.authorize = newAuthorizationHandler(p, b.log).authorize
+	return b
+}
+
+// TenantFromApiKey resolves tenant, user and scopes of the caller from an API key
+func (b *ginConfigBuilder) TenantFromApiKey(p ApiKeyConfig) ConfigBuilder {
+	b.config.tenant = newApiKeyHandler(p, b.log).createTenantFromApiKey
+	b.config.info.Tenant = RouteModeApiKey
+	return b
+}
+
+// SignedRequests accepts only the requests signed by a SigningTransport with one of the key aliases of p
+func (b *ginConfigBuilder) SignedRequests(p HmacConfig) ConfigBuilder {
+	if p.Module == nil || len(p.KeyAliases) == 0 {
+		panic("Signed requests need a secure module and at least one key alias")
+	}
+
+	b.config.signature = newHmacHandler(p, b.log).verifySignature
+	return b
+}
+
+// SignResponses adds to every response a detached JWS of its body, see VerifyDetachedJws
+func (b *ginConfigBuilder) SignResponses(p JwsConfig) ConfigBuilder {
+	if p.Module == nil || p.KeyAlias == "" {
+		panic("Signed responses need a secure module and a key alias")
+	}
+
+	b.config.jws = newJwsHandler(p, b.log).signResponse
+	return b
+}
+
+// MaxBodySize rejects with 413 the requests with a body larger than p bytes, overriding
+// ServerOptions.MaxBodyBytes. A negative value disables the limit for the route
+func (b *ginConfigBuilder) MaxBodySize(p int64) ConfigBuilder {
+	b.config.maxBody = p
+	return b
+}
+
+// Deprecated marks the route as deprecated, announcing it to the callers through the
+// Deprecation, Sunset and Link headers and logging them by tenant
+func (b *ginConfigBuilder) Deprecated(p DeprecationConfig) ConfigBuilder {
+	b.config.deprecation = newDeprecationHandler(p, b.log).handleDeprecation
+	return b
+}
+
+// Async answers 202 with a job queued on p, which runs the service in its own managed
+// transaction, opened with the route CircuitBreaker and TxOptions. The route transaction
+// and after run hooks are ignored, see Http.AddJobRoutes
+func (b *ginConfigBuilder) Async(p *JobRunner) ConfigBuilder {
+	b.config.async = p
+	b.config.info.Kind = RouteKindAsync
+	b.config.info.Tx = RouteModeManaged
+	return b
+}
+
+// Cache serves the responses of a GET route from p.Cache until they expire or are invalidated
+func (b *ginConfigBuilder) Cache(p CacheConfig) ConfigBuilder {
+	b.config.cache = newCacheHandler(p, b.log).handleCache
+	b.config.cacheShared = p.Shared
+	return b
+}
+
+// RequireFeature exposes the route only to the callers for which the feature flag is on.
+// The flags are evaluated with the store of ServerOptions.Features
+func (b *ginConfigBuilder) RequireFeature(name string) ConfigBuilder {
+	b.config.features = append(b.config.features, name)
+	return b
+}
+
+// CircuitBreaker guards the transactions opened by the route with breaker, failing fast
+// while the storage is down. It applies to the managed and unmanaged transactions whether
+// it is set before or after Tx
+func (b *ginConfigBuilder) CircuitBreaker(breaker *CircuitBreaker) ConfigBuilder {
+	b.breaker = breaker
+	b.reapplyTx()
+	return b
+}
+
+// Retry re-runs the service in a fresh transaction when it or the commit fail with a
+// retryable error. It needs a managed transaction and it can be set before or after Tx
+func (b *ginConfigBuilder) Retry(policy RetryPolicy) ConfigBuilder {
+	b.retry = &policy
+	b.config.retry = newRetryHandler(policy, transactionHandler{log: b.log, db: b.db, breaker: b.breaker, options: b.options}, b.log)
+	b.reapplyTx()
+	return b
+}
+
+// TxOptions opens the transactions of the route with isolation level, read-only mode and
+// deadline, on the storages implementing storage.OptionsContext. Without options, GET and
+// HEAD routes open read-only transactions. It can be set before or after Tx
+func (b *ginConfigBuilder) TxOptions(options rstorage.TxOptions) ConfigBuilder {
+	b.options = &options
+	b.reapplyTx()
+	return b
+}
+
 func (b *ginConfigBuilder) Build() api.Config {
+	// jobs open their transaction from the runner storage with the breaker and options of the route
+	if b.config.async != nil {
+		b.config.jobTx = transactionHandler{log: b.log, db: b.config.async.config.Storage, breaker: b.breaker, options: b.options}
+	}
+
 	return b.config
 }
@@ -0,0 +1,231 @@
+package gin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/tenant"
+)
+
+var defaultCorsHeaders = []string{"Content-Type", "X-Correlation-ID", "X-Tenant-ID", "X-Tenant-UserID"}
+
+// CorsConfig defines the cross origin resource sharing policy of a route.
+// Origins can be granted globally or only to the requests of a specific tenant
+type CorsConfig struct {
+	// Origins allowed for every tenant. Use "*" to allow any origin, incompatible with AllowCredentials
+	AllowedOrigins []string
+
+	// Origins allowed only for the requests of the mapped tenant ID
+	TenantOrigins map[string][]string
+
+	// Methods allowed by the preflight. Empty means all methods registered on the path
+	AllowedMethods []string
+
+	// Request headers allowed by the preflight. Use "*" to allow any header
+	AllowedHeaders []string
+
+	// Response headers the browser is allowed to read
+	ExposedHeaders []string
+
+	// Allow cookies and authorization headers to be sent by the browser
+	AllowCredentials bool
+
+	// How long the browser can cache the preflight response. Zero disables the header
+	MaxAge time.Duration
+}
+
+func (c CorsConfig) originAllowed(origin string, tenantID string) bool {
+	if contains(c.AllowedOrigins, "*") || contains(c.AllowedOrigins, origin) {
+		return true
+	}
+
+	if tenantID != "" {
+		return contains(c.TenantOrigins[tenantID], origin)
+	}
+
+	// preflight requests do not carry tenant headers, so any tenant origin
+	// is accepted here and checked again on the actual request.
+	for _, origins := range c.TenantOrigins {
+		if contains(origins, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c CorsConfig) headersAllowed(requested string) bool {
+	allowed := c.AllowedHeaders
+	if len(allowed) == 0 {
+		allowed = defaultCorsHeaders
+	}
+
+	if contains(allowed, "*") {
+		return true
+	}
+
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h != "" && !containsFold(allowed, h) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setOriginHeaders grants origin. A wildcard policy answers with a literal "*", which
+// browsers never combine with credentials
+func (c CorsConfig) setOriginHeaders(ctx *gin.Context, origin string) {
+	if contains(c.AllowedOrigins, "*") {
+		ctx.Header("Access-Control-Allow-Origin", "*")
+		return
+	}
+
+	ctx.Header("Access-Control-Allow-Origin", origin)
+
+	if c.AllowCredentials {
+		ctx.Header("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(items []string, item string) bool {
+	for _, i := range items {
+		if strings.EqualFold(i, item) {
+			return true
+		}
+	}
+	return false
+}
+
+type corsHandler struct {
+	config CorsConfig
+	log    logging.Logger
+}
+
+// handleCors runs before the tenant stage, so the errors answered while resolving the tenant
+// carry the CORS headers too. Tenant origins are granted here like in the preflight requests,
+// then checked again by checkTenantOrigin once the tenant is resolved
+func (g corsHandler) handleCors(ctx *gin.Context) {
+	// the CORS headers depend on the origin, caches must not share the response between origins
+	ctx.Writer.Header().Add("Vary", "Origin")
+
+	origin := ctx.GetHeader("Origin")
+	if origin == "" {
+		ctx.Next()
+		return
+	}
+
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	if !g.config.originAllowed(origin, "") {
+		g.log.Debug(logCtx, "Origin %s not allowed", origin)
+		ctx.Next()
+		return
+	}
+
+	g.config.setOriginHeaders(ctx, origin)
+
+	if len(g.config.ExposedHeaders) > 0 {
+		ctx.Header("Access-Control-Expose-Headers", strings.Join(g.config.ExposedHeaders, ", "))
+	}
+
+	ctx.Next()
+}
+
+// checkTenantOrigin withdraws the CORS headers granted to an origin of another tenant
+func (g corsHandler) checkTenantOrigin(ctx *gin.Context) {
+	origin := ctx.GetHeader("Origin")
+	if origin == "" || ctx.Writer.Header().Get("Access-Control-Allow-Origin") == "" {
+		ctx.Next()
+		return
+	}
+
+	// logging and tenant keys are always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	iTenantCtx, _ := ctx.Get(api.TenantKey)
+	tenantCtx := iTenantCtx.(tenant.Context)
+
+	if !g.config.originAllowed(origin, tenantCtx.ID()) {
+		g.log.Debug(logCtx, "Origin %s not allowed for tenant %s", origin, tenantCtx.ID())
+
+		header := ctx.Writer.Header()
+		header.Del("Access-Control-Allow-Origin")
+		header.Del("Access-Control-Allow-Credentials")
+		header.Del("Access-Control-Expose-Headers")
+	}
+
+	ctx.Next()
+}
+
+// corsPreflight answers OPTIONS requests of a path shared by one or more routes
+type corsPreflight struct {
+	config  CorsConfig
+	methods []string
+	log     logging.Logger
+}
+
+func (p *corsPreflight) allowedMethods() []string {
+	if len(p.config.AllowedMethods) > 0 {
+		return p.config.AllowedMethods
+	}
+	return p.methods
+}
+
+func (p *corsPreflight) handlePreflight(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	ctx.Writer.Header().Add("Vary", "Origin")
+
+	origin := ctx.GetHeader("Origin")
+	method := ctx.GetHeader("Access-Control-Request-Method")
+
+	if origin == "" || method == "" {
+		ctx.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+
+	if !p.config.originAllowed(origin, "") || !containsFold(p.allowedMethods(), method) ||
+		!p.config.headersAllowed(ctx.GetHeader("Access-Control-Request-Headers")) {
+		p.log.Warn(logCtx, "Rejected preflight request from origin %s for method %s", origin, method)
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	p.config.setOriginHeaders(ctx, origin)
+	ctx.Header("Access-Control-Allow-Methods", strings.Join(p.allowedMethods(), ", "))
+
+	if contains(p.config.AllowedHeaders, "*") {
+		ctx.Header("Access-Control-Allow-Headers", ctx.GetHeader("Access-Control-Request-Headers"))
+	} else if len(p.config.AllowedHeaders) > 0 {
+		ctx.Header("Access-Control-Allow-Headers", strings.Join(p.config.AllowedHeaders, ", "))
+	} else {
+		ctx.Header("Access-Control-Allow-Headers", strings.Join(defaultCorsHeaders, ", "))
+	}
+
+	if p.config.MaxAge > 0 {
+		ctx.Header("Access-Control-Max-Age", strconv.Itoa(int(p.config.MaxAge.Seconds())))
+	}
+
+	ctx.AbortWithStatus(http.StatusNoContent)
+}
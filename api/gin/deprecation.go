@@ -0,0 +1,103 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/tenant"
+)
+
+// DeprecationConfig declares a route as deprecated
+type DeprecationConfig struct {
+	// Date since the route is deprecated. Zero means deprecated since now
+	Since time.Time
+
+	// Date after which the route is no longer available, sent in the Sunset header
+	Sunset time.Time
+
+	// Documentation of the deprecation, linked with rel="deprecation"
+	Link string
+
+	// Route replacing the deprecated one, linked with rel="successor-version"
+	Successor string
+
+	// Answers 410 Gone after the Sunset date instead of running the route
+	EnforceSunset bool
+}
+
+type deprecationHandler struct {
+	config DeprecationConfig
+	since  time.Time
+	log    logging.Logger
+}
+
+func newDeprecationHandler(config DeprecationConfig, log logging.Logger) deprecationHandler {
+	if config.EnforceSunset && config.Sunset.IsZero() {
+		panic("Enforcing the sunset of a deprecated route needs a sunset date")
+	}
+
+	since := config.Since
+	if since.IsZero() {
+		since = time.Now()
+	}
+
+	return deprecationHandler{config: config, since: since, log: log}
+}
+
+// headers sets Deprecation (RFC 9745), Sunset (RFC 8594) and Link headers
+func (g deprecationHandler) headers(ctx *gin.Context) {
+	ctx.Header("Deprecation", fmt.Sprintf("@%d", g.since.Unix()))
+
+	if !g.config.Sunset.IsZero() {
+		ctx.Header("Sunset", g.config.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	var links []string
+	if g.config.Link != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"deprecation\"", g.config.Link))
+	}
+
+	if g.config.Successor != "" {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"successor-version\"", g.config.Successor))
+	}
+
+	if len(links) > 0 {
+		ctx.Writer.Header().Add("Link", strings.Join(links, ", "))
+	}
+}
+
+func (g deprecationHandler) handleDeprecation(ctx *gin.Context) {
+	// logging and tenant keys are always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	iTenantCtx, _ := ctx.Get(api.TenantKey)
+	tenantCtx := iTenantCtx.(tenant.Context)
+
+	g.headers(ctx)
+
+	if g.config.EnforceSunset && time.Now().After(g.config.Sunset) {
+		g.log.Warn(logCtx, "Rejected call to retired route %s %s by user %s of tenant %s",
+			ctx.Request.Method, ctx.FullPath(), tenantCtx.UserID(), tenantCtx.ID())
+
+		abortWithError(ctx, http.StatusGone, api.Model{
+			Error: api.ErrorModel{
+				Code:   ApiErrorGone,
+				Msg:    "API no longer available",
+				DevMsg: fmt.Sprintf("Route retired on %s", g.config.Sunset.UTC().Format(time.RFC3339)),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	g.log.Warn(logCtx, "Deprecated route %s %s called by user %s of tenant %s",
+		ctx.Request.Method, ctx.FullPath(), tenantCtx.UserID(), tenantCtx.ID())
+
+	ctx.Next()
+}
@@ -0,0 +1,77 @@
+package gin
+
+import (
+	"net/http"
+
+	"github.com/hellcats88/abstracte/api"
+)
+
+// Error codes added by the gin implementation on top of the api.ApiError ones.
+// Services can return them as ServiceOutput status like any other code
+const (
+	// ApiErrorConflict means the request conflicts with the current state of the entity
+	ApiErrorConflict api.ApiError = 0x100
+
+	// ApiErrorPayloadTooLarge means the request body exceeds the size accepted by the route
+	ApiErrorPayloadTooLarge api.ApiError = 0x101
+
+	// ApiErrorGone means the route has been retired after its sunset date
+	ApiErrorGone api.ApiError = 0x102
+
+	// ApiErrorUnavailable means the service is temporarily unable to handle the request
+	ApiErrorUnavailable api.ApiError = 0x103
+)
+
+// httpStatus maps a service status into the HTTP status code of the response
+func httpStatus(code api.ApiError) int {
+	switch code {
+	case api.ApiErrorNoError:
+		return http.StatusOK
+	case api.ApiErrorAuthFailed:
+		return http.StatusForbidden
+	case api.ApiErrorEntityAlreadyExists:
+		return http.StatusConflict
+	case api.ApiErrorEntityDoesNotExists:
+		return http.StatusNotFound
+	case api.ApiErrorMissingRequiredItem:
+		return http.StatusBadRequest
+	case api.ApiErrorUnexpected:
+		return http.StatusInternalServerError
+	case api.ApiErrorUnknownItemRequested:
+		return http.StatusBadRequest
+	case ApiErrorConflict:
+		return http.StatusConflict
+	case ApiErrorPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case ApiErrorGone:
+		return http.StatusGone
+	case ApiErrorUnavailable:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// serviceOutput is the api.ServiceOutput built by the gin helpers
+type serviceOutput struct {
+	status api.ApiError
+	err    error
+	msg    string
+	data   interface{}
+}
+
+func (o serviceOutput) Status() api.ApiError {
+	return o.status
+}
+
+func (o serviceOutput) Err() error {
+	return o.err
+}
+
+func (o serviceOutput) ErrMessage() string {
+	return o.msg
+}
+
+func (o serviceOutput) ResponseModel() interface{} {
+	return o.data
+}
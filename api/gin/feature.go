@@ -0,0 +1,51 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/env"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/tenant"
+	"github.com/hellcats88/rem/feature"
+)
+
+type featureHandler struct {
+	features []string
+	store    *feature.Store
+	env      env.Context
+	log      logging.Logger
+}
+
+// gate hides the route, as if it did not exist, to the callers for which a required flag is off
+func (g featureHandler) gate(ctx *gin.Context) {
+	// logging and tenant keys are always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	iTenantCtx, _ := ctx.Get(api.TenantKey)
+	tenantCtx := iTenantCtx.(tenant.Context)
+
+	target := feature.Target{TenantID: tenantCtx.ID(), UserID: tenantCtx.UserID(), Env: g.env.Name()}
+
+	for _, name := range g.features {
+		if !g.store.Evaluate(name, target) {
+			g.log.Debug(logCtx, "Feature %s is off for user %s of tenant %s. Route %s %s not available",
+				name, tenantCtx.UserID(), tenantCtx.ID(), ctx.Request.Method, ctx.FullPath())
+
+			abortWithError(ctx, http.StatusNotFound, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorEntityDoesNotExists,
+					Msg:    "API not available",
+					DevMsg: fmt.Sprintf("Feature %s is not enabled", name),
+					CorrId: logCtx.CorrID(),
+				},
+			})
+			return
+		}
+	}
+
+	ctx.Next()
+}
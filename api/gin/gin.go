@@ -1,7 +1,12 @@
 package gin
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hellcats88/abstracte/api"
@@ -9,17 +14,58 @@ import (
 	"github.com/hellcats88/abstracte/runtime"
 )
 
+// Http extends api.Http with the gin specific features. It is also an http.Handler,
+// so routes can be served in process without opening a listener
+type Http interface {
+	api.Http
+	http.Handler
+
+	// AddJsonRpc exposes the methods registered on rpc as a JSON-RPC 2.0 endpoint on POST path
+	AddJsonRpc(path string, rpc *JsonRpc) error
+
+	// AddJobRoutes exposes on GET path/:id the status and on GET path/:id/result the result
+	// of the jobs of runner. Jobs are visible only to the tenant resolved by config
+	AddJobRoutes(path string, config api.Config, runner *JobRunner) error
+
+	// AddBatchRoute exposes on POST path a route running a list of sub-requests against
+	// the registered routes in one managed transaction, committed only if all of them succeed.
+	// Sub-requests targeting unmanaged, custom transaction or asynchronous routes are rejected
+	AddBatchRoute(path string, config BatchConfig) error
+
+	// Serve serves the routes on all the listeners simultaneously, see TCPListener,
+	// UnixListener and SystemdListeners. It stops serving on all of them at the first error
+	Serve(listeners ...net.Listener) error
+
+	// Shutdown gracefully stops the servers started by Listen and Serve
+	Shutdown(ctx context.Context) error
+
+	// Routes lists the routes added so far with the stages of their pipelines
+	Routes() []RouteInfo
+
+	// Maintenance returns the switch toggling the maintenance of the routes at runtime
+	Maintenance() *MaintenanceSwitch
+}
+
 type ginHttp struct {
-	engine *gin.Engine
-	log    logging.Logger
+	engine      *gin.Engine
+	log         logging.Logger
+	preflights  map[string]*corsPreflight
+	options     ServerOptions
+	running     *runningServers
+	registry    *routeRegistry
+	maintenance *MaintenanceSwitch
 }
 
-func New(log logging.Logger) api.Http {
+func New(log logging.Logger) Http {
 	engine := gin.Default()
 
 	entity := ginHttp{
-		engine: engine,
-		log:    log,
+		engine:      engine,
+		log:         log,
+		preflights:  make(map[string]*corsPreflight),
+		running:     &runningServers{},
+		registry:    &routeRegistry{},
+		maintenance: NewMaintenanceSwitch(),
 	}
 
 	return entity
@@ -49,7 +95,123 @@ func (g ginHttp) AddRoute(method string, path string, config api.Config, service
 	var handlers []gin.HandlerFunc
 	ginCnf := config.(ginConfig)
 
-	handlers = append(handlers, ginCnf.log, ginCnf.tenant, ginCnf.tx)
+	if ginCnf.cache != nil && method != http.MethodGet && method != http.MethodHead {
+		return fmt.Errorf("Route %s %s cannot be cached, only GET and HEAD routes can", method, path)
+	}
+
+	if ginCnf.cache != nil && ginCnf.info.Tenant == RouteModeNone && !ginCnf.cacheShared {
+		return fmt.Errorf("Route %s %s resolves no tenant, its cached responses would be shared by all the callers. Set CacheConfig.Shared to allow it", method, path)
+	}
+
+	if ginCnf.async != nil && len(ginCnf.afterRun) > 0 {
+		return fmt.Errorf("Route %s %s cannot run afterRun stages, asynchronous routes answer before the service runs", method, path)
+	}
+
+	if ginCnf.retry != nil && (ginCnf.info.Tx != RouteModeManaged || ginCnf.async != nil) {
+		return fmt.Errorf("Route %s %s cannot retry its service, only synchronous managed transaction routes can", method, path)
+	}
+
+	if preflight, exist := g.preflights[path]; exist && ginCnf.cors != nil && !reflect.DeepEqual(preflight.config, *ginCnf.cors) {
+		return fmt.Errorf("Route %s %s cannot change the CORS policy of its path, routes sharing a path share the preflight", method, path)
+	}
+
+	if len(ginCnf.features) > 0 && g.options.Features == nil {
+		return fmt.Errorf("Route %s %s requires feature flags but no feature store is configured", method, path)
+	}
+
+	info := ginCnf.info
+	info.Method = method
+	info.Path = path
+	info.Stages = nil
+	info.BeforeRun = len(ginCnf.beforeRun)
+	info.AfterRun = len(ginCnf.afterRun)
+
+	rt := newRuntimeHandler(g.options)
+	handlers = append(handlers, ginCnf.log)
+
+	if localize := g.localization(); localize != nil {
+		handlers = append(handlers, localize)
+		info.Stages = append(info.Stages, "localization")
+	}
+
+	if limit := g.bodyLimit(ginCnf.maxBody); limit != nil {
+		handlers = append(handlers, limit)
+		info.Stages = append(info.Stages, "bodyLimit")
+	}
+
+	// the signature covers the payload as sent, so it is verified before decompression
+	if ginCnf.signature != nil {
+		handlers = append(handlers, ginCnf.signature)
+		info.Stages = append(info.Stages, "signature")
+	}
+
+	if ginCnf.compression != nil {
+		handlers = append(handlers, ginCnf.compression)
+		info.Stages = append(info.Stages, "compression")
+	}
+
+	// responses are signed before compression, so the signature covers the api.Model body
+	if ginCnf.jws != nil {
+		handlers = append(handlers, ginCnf.jws)
+		info.Stages = append(info.Stages, "jws")
+	}
+
+	// the errors of the tenant stage must be readable by the browsers too
+	if ginCnf.cors != nil {
+		cors := corsHandler{config: *ginCnf.cors, log: g.log}
+		handlers = append(handlers, cors.handleCors, ginCnf.tenant, cors.checkTenantOrigin)
+		g.addPreflight(method, path, *ginCnf.cors)
+		info.Stages = append(info.Stages, "cors")
+	} else {
+		handlers = append(handlers, ginCnf.tenant)
+	}
+
+	handlers = append(handlers, maintenanceHandler{
+		maintenance: g.maintenance,
+		env:         rt.env.Name(),
+		writes:      isWrite(method, info.Tx),
+		log:         g.log,
+	}.checkMaintenance)
+	info.Stages = append(info.Stages, "maintenance")
+
+	if len(ginCnf.features) > 0 {
+		handlers = append(handlers, featureHandler{
+			features: ginCnf.features,
+			store:    g.options.Features,
+			env:      rt.env,
+			log:      g.log,
+		}.gate)
+		info.Features = ginCnf.features
+		info.Stages = append(info.Stages, "feature")
+	}
+
+	if ginCnf.deprecation != nil {
+		handlers = append(handlers, ginCnf.deprecation)
+		info.Stages = append(info.Stages, "deprecation")
+	}
+
+	if ginCnf.authorize != nil {
+		handlers = append(handlers, ginCnf.authorize)
+		info.Stages = append(info.Stages, "authorize")
+	}
+
+	// cached responses are served without opening a transaction
+	if ginCnf.cache != nil {
+		handlers = append(handlers, ginCnf.cache)
+		info.Stages = append(info.Stages, "cache")
+	}
+
+	// these routes cannot join the transaction of a batch, they commit on their own
+	if ginCnf.async != nil || ginCnf.info.Tx == RouteModeUnmanaged || ginCnf.info.Tx == RouteModeCustom {
+		handlers = append(handlers, rejectInBatch)
+	}
+
+	// jobs open their own transaction when they run
+	if ginCnf.async != nil {
+		handlers = append(handlers, transactionHandler{log: g.log}.createNoTransaction)
+	} else {
+		handlers = append(handlers, ginCnf.tx)
+	}
 
 	if ginCnf.headers != nil {
 		handlers = append(handlers, ginCnf.headers)
@@ -71,23 +233,146 @@ func (g ginHttp) AddRoute(method string, path string, config api.Config, service
 		handlers = append(handlers, ginCnf.beforeRun...)
 	}
 
-	handlers = append(handlers, runtimeHandler{}.createRuntimeContext, g.wrapService(service))
+	if ginCnf.async != nil {
+		handlers = append(handlers, asyncHandler{runner: ginCnf.async, tx: ginCnf.jobTx, service: service, runtime: rt, log: g.log}.enqueue)
+
+		g.engine.Handle(method, path, handlers...)
+		g.registry.add(info)
+		return nil
+	}
+
+	// the retry stage commits each attempt itself, replacing the commit stage
+	if ginCnf.retry != nil {
+		handlers = append(handlers, rt.createRuntimeContext, ginCnf.retry.runService(service))
+		info.Stages = append(info.Stages, "retry")
+	} else {
+		handlers = append(handlers, rt.createRuntimeContext, g.wrapService(service))
+	}
 
 	//reverse order due to recursive logic of gin middlewares
 	handlers = append(handlers, resultHandler{log: g.log}.handleResult)
 
 	if ginCnf.afterRun != nil && len(ginCnf.afterRun) > 0 {
-		handlers = append(handlers, reverse(ginCnf.afterRun)...)
+		// reverse a copy, the config may be shared by several routes
+		handlers = append(handlers, reverse(append([]gin.HandlerFunc(nil), ginCnf.afterRun...))...)
 	}
 
-	if ginCnf.commit != nil {
+	if ginCnf.commit != nil && ginCnf.retry == nil {
 		handlers = append(handlers, ginCnf.commit)
 	}
 
 	g.engine.Handle(method, path, handlers...)
+	g.registry.add(info)
+	return nil
+}
+
+// addPreflight registers the OPTIONS route answering CORS preflight requests of path.
+// Routes sharing the same path share the preflight, which allows all their methods, so AddRoute
+// rejects the routes of a path with different CORS policies.
+func (g ginHttp) addPreflight(method string, path string, config CorsConfig) {
+	if method == http.MethodOptions {
+		return
+	}
+
+	if preflight, exist := g.preflights[path]; exist {
+		preflight.methods = append(preflight.methods, method)
+		return
+	}
+
+	preflight := &corsPreflight{config: config, methods: []string{method}, log: g.log}
+	g.preflights[path] = preflight
+	g.engine.OPTIONS(path, logHandler{}.createLogContext, preflight.handlePreflight)
+}
+
+// bodyLimit returns the stage enforcing the route body limit, falling back on the server one
+func (g ginHttp) bodyLimit(routeLimit int64) gin.HandlerFunc {
+	if routeLimit == 0 {
+		routeLimit = g.options.MaxBodyBytes
+	}
+
+	if routeLimit <= 0 {
+		return nil
+	}
+
+	return bodyLimitHandler{limit: routeLimit, log: g.log}.limitBody
+}
+
+// localization returns the stage enabling the translation of the error messages, if configured
+func (g ginHttp) localization() gin.HandlerFunc {
+	if g.options.Localization.Catalog == nil {
+		return nil
+	}
+
+	return localizationHandler{config: g.options.Localization}.setLocalizer
+}
+
+// internalStages returns the stages shared by the JSON-RPC and batch routes, with their registry names
+func (g ginHttp) internalStages() ([]gin.HandlerFunc, []string) {
+	handlers := []gin.HandlerFunc{logHandler{}.createLogContext}
+	var stages []string
+
+	if localize := g.localization(); localize != nil {
+		handlers = append(handlers, localize)
+		stages = append(stages, "localization")
+	}
+
+	if limit := g.bodyLimit(0); limit != nil {
+		handlers = append(handlers, limit)
+		stages = append(stages, "bodyLimit")
+	}
+
+	return handlers, stages
+}
+
+func (g ginHttp) AddJsonRpc(path string, rpc *JsonRpc) error {
+	handlers, stages := g.internalStages()
+
+	g.engine.POST(path, append(handlers, rpc.handler(newRuntimeHandler(g.options)))...)
+
+	// the tenant is resolved by each JSON-RPC method according to its own configuration
+	g.registry.add(RouteInfo{Method: http.MethodPost, Path: path, Kind: RouteKindJsonRpc, Tenant: RouteModeCustom, Tx: RouteModeNone,
+		Stages: stages})
+	return nil
+}
+
+func (g ginHttp) AddJobRoutes(path string, config api.Config, runner *JobRunner) error {
+	path = strings.TrimSuffix(path, "/")
+	runner.statusPath = path
+
+	if err := g.AddRoute(http.MethodGet, path+"/:id", config, jobService(runner, false)); err != nil {
+		return err
+	}
+
+	return g.AddRoute(http.MethodGet, path+"/:id/result", config, jobService(runner, true))
+}
+
+func (g ginHttp) AddBatchRoute(path string, config BatchConfig) error {
+	if config.Storage == nil {
+		return fmt.Errorf("Batch route %s needs a storage context", path)
+	}
+
+	if config.MaxItems == 0 {
+		config.MaxItems = 100
+	}
+
+	handlers, stages := g.internalStages()
+
+	handler := batchHandler{config: config, path: path, engine: g.engine, log: g.log}
+	g.engine.POST(path, append(handlers, handler.handleBatch)...)
+	g.registry.add(RouteInfo{Method: http.MethodPost, Path: path, Kind: RouteKindBatch, Tenant: RouteModeNone, Tx: RouteModeManaged,
+		Stages: stages})
 	return nil
 }
 
+func (g ginHttp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.engine.ServeHTTP(w, r)
+}
+
 func (g ginHttp) Listen(port int, address string) error {
-	return g.engine.Run(fmt.Sprintf("%s:%d", address, port))
+	listener, err := TCPListener(address, port)
+	if err != nil {
+		return err
+	}
+
+	return g.Serve(listener)
 }
@@ -3,7 +3,11 @@ module github.com/hellcats88/rem/api/gin
 go 1.15
 
 require (
-	github.com/gin-gonic/gin v1.6.3
+	github.com/andybalholm/brotli v1.0.4
+	github.com/gin-gonic/gin v1.7.7
+	github.com/google/uuid v1.2.0
 	github.com/hellcats88/abstracte v0.0.0-20210531083423-6e6de49b09ed
-	github.com/hellcats88/rem v0.0.0-20210317090745-a31d710583e4
+	github.com/hellcats88/rem v0.0.0-20261019172829-746556a5853c
+	github.com/klauspost/compress v1.13.6
+	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2
 )
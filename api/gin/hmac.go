@@ -0,0 +1,339 @@
+package gin
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	aruntime "github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/abstracte/security"
+	rlog "github.com/hellcats88/rem/logging"
+	"github.com/hellcats88/rem/runtime"
+	"github.com/hellcats88/rem/tenant"
+)
+
+const (
+	// HeaderSignature carries the base64 HMAC-SHA256 of the canonical request
+	HeaderSignature = "X-Rem-Signature"
+
+	// HeaderSignatureKey carries the secure module alias of the signing key
+	HeaderSignatureKey = "X-Rem-Signature-Key"
+
+	// HeaderTimestamp carries the signing time as Unix seconds
+	HeaderTimestamp = "X-Rem-Timestamp"
+
+	// HeaderNonce carries a value unique for every signed request
+	HeaderNonce = "X-Rem-Nonce"
+)
+
+var hmacKeyLabel = []byte("rem-hmac-sha256-key")
+
+// NonceCache remembers the nonces of the signed requests already accepted
+type NonceCache interface {
+	// Seen reports if nonce has already been used, otherwise records it until expiresAt
+	Seen(nonce string, expiresAt time.Time) bool
+}
+
+// MemoryNonceCache is an in memory NonceCache, safe for concurrent use.
+// Expired nonces are swept at most once per minute, keeping Seen constant time on average
+type MemoryNonceCache struct {
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	nextSweep time.Time
+}
+
+// nonceSweepInterval is the minimum time between two sweeps of the expired nonces
+const nonceSweepInterval = time.Minute
+
+// NewMemoryNonceCache creates an empty in memory nonce cache
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{nonces: make(map[string]time.Time), nextSweep: time.Now().Add(nonceSweepInterval)}
+}
+
+func (c *MemoryNonceCache) Seen(nonce string, expiresAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if exp, exist := c.nonces[nonce]; exist && now.Before(exp) {
+		return true
+	}
+
+	if now.After(c.nextSweep) {
+		for n, exp := range c.nonces {
+			if now.After(exp) {
+				delete(c.nonces, n)
+			}
+		}
+		c.nextSweep = now.Add(nonceSweepInterval)
+	}
+
+	c.nonces[nonce] = expiresAt
+	return false
+}
+
+// HmacConfig defines the verification of service to service signed requests
+type HmacConfig struct {
+	// Secure module holding the symmetric signing keys
+	Module security.SecureModule
+
+	// Aliases of the keys accepted by the route
+	KeyAliases []string
+
+	// Maximum difference between the request timestamp and the server clock. Default: 5 minutes
+	ClockSkew time.Duration
+
+	// Cache of the accepted nonces. Default: in memory cache
+	Nonces NonceCache
+
+	// Time the keys derived from the secure module are cached, so rotated keys are picked
+	// up without restarts. Default: 5 minutes
+	KeyTTL time.Duration
+}
+
+// defaultHmacKeyTTL is the time the derived keys are cached by default
+const defaultHmacKeyTTL = 5 * time.Minute
+
+// hmacKeyring derives and caches for ttl the HMAC keys of the secure module aliases
+type hmacKeyring struct {
+	module security.SecureModule
+	ttl    time.Duration
+	keys   sync.Map
+}
+
+type hmacKey struct {
+	key       []byte
+	expiresAt time.Time
+}
+
+// deriveHmacKey expands the symmetric key of block into a 32 bytes HMAC key, encrypting
+// counter blocks built from a fixed label, so the raw key never leaves the secure module
+func deriveHmacKey(block cipher.Block) []byte {
+	key := make([]byte, 0, sha256.Size)
+	in := make([]byte, block.BlockSize())
+	out := make([]byte, block.BlockSize())
+
+	for counter := byte(0); len(key) < sha256.Size; counter++ {
+		copy(in, hmacKeyLabel)
+		in[len(in)-1] = counter
+		block.Encrypt(out, in)
+		key = append(key, out...)
+	}
+
+	return key[:sha256.Size]
+}
+
+func (k *hmacKeyring) key(ctx aruntime.Context, alias string) ([]byte, error) {
+	if cached, exist := k.keys.Load(alias); exist && time.Now().Before(cached.(hmacKey).expiresAt) {
+		return cached.(hmacKey).key, nil
+	}
+
+	block, err := k.module.Block(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	key := deriveHmacKey(block)
+	k.keys.Store(alias, hmacKey{key: key, expiresAt: time.Now().Add(k.ttl)})
+	return key, nil
+}
+
+// CanonicalRequest builds the message signed for a request. requestURI includes the query string
+func CanonicalRequest(method string, requestURI string, timestamp string, nonce string, body []byte, tenantID string, userID string) []byte {
+	digest := sha256.Sum256(body)
+
+	var buf bytes.Buffer
+	for _, part := range []string{method, requestURI, timestamp, nonce, hex.EncodeToString(digest[:]), tenantID, userID} {
+		buf.WriteString(part)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes()
+}
+
+func sign(key []byte, message []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+type hmacHandler struct {
+	config  HmacConfig
+	keyring *hmacKeyring
+	log     logging.Logger
+}
+
+func newHmacHandler(config HmacConfig, log logging.Logger) hmacHandler {
+	if config.ClockSkew == 0 {
+		config.ClockSkew = 5 * time.Minute
+	}
+
+	if config.Nonces == nil {
+		config.Nonces = NewMemoryNonceCache()
+	}
+
+	if config.KeyTTL <= 0 {
+		config.KeyTTL = defaultHmacKeyTTL
+	}
+
+	return hmacHandler{config: config, keyring: &hmacKeyring{module: config.Module, ttl: config.KeyTTL}, log: log}
+}
+
+func (g hmacHandler) reject(ctx *gin.Context, logCtx logging.Context, devMsg string) {
+	g.log.Error(logCtx, "Rejected request caused by invalid signature. %s", devMsg)
+
+	abortWithError(ctx, http.StatusUnauthorized, api.Model{
+		Error: api.ErrorModel{
+			Code:   api.ApiErrorAuthFailed,
+			Msg:    "Failed to verify request signature",
+			DevMsg: devMsg,
+			CorrId: logCtx.CorrID(),
+		},
+	})
+}
+
+func (g hmacHandler) verifySignature(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	alias := ctx.GetHeader(HeaderSignatureKey)
+	if !contains(g.config.KeyAliases, alias) {
+		g.reject(ctx, logCtx, fmt.Sprintf("Signing key %q not accepted", alias))
+		return
+	}
+
+	timestamp := ctx.GetHeader(HeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		g.reject(ctx, logCtx, "Invalid signature timestamp")
+		return
+	}
+
+	signedAt := time.Unix(seconds, 0)
+	if skew := time.Since(signedAt); skew > g.config.ClockSkew || skew < -g.config.ClockSkew {
+		g.reject(ctx, logCtx, fmt.Sprintf("Signature timestamp outside of the allowed window of %v", g.config.ClockSkew))
+		return
+	}
+
+	nonce := ctx.GetHeader(HeaderNonce)
+	if nonce == "" {
+		g.reject(ctx, logCtx, "Missing signature nonce")
+		return
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(ctx.GetHeader(HeaderSignature))
+	if err != nil || len(signature) == 0 {
+		g.reject(ctx, logCtx, "Missing or malformed signature")
+		return
+	}
+
+	var body []byte
+	if ctx.Request.Body != nil {
+		if body, err = ioutil.ReadAll(ctx.Request.Body); err != nil {
+			g.reject(ctx, logCtx, fmt.Sprintf("Failed to read payload. %v", err))
+			return
+		}
+		ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	rCtx := runtime.New(logCtx, txNoOp{}, tenant.NewEmpty())
+	key, err := g.keyring.key(rCtx, alias)
+	if err != nil {
+		g.log.Error(logCtx, "Failed to load signing key %s. %v", alias, err)
+
+		abortWithError(ctx, http.StatusInternalServerError, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Failed to verify request signature",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	message := CanonicalRequest(ctx.Request.Method, ctx.Request.URL.RequestURI(), timestamp, nonce, body,
+		ctx.GetHeader("X-Tenant-ID"), ctx.GetHeader("X-Tenant-UserID"))
+
+	if !hmac.Equal(signature, sign(key, message)) {
+		g.reject(ctx, logCtx, "Signature mismatch")
+		return
+	}
+
+	// nonces are recorded only for authentic requests, so they cannot be exhausted by forgeries
+	if g.config.Nonces.Seen(alias+":"+nonce, signedAt.Add(g.config.ClockSkew)) {
+		g.reject(ctx, logCtx, "Replayed request")
+		return
+	}
+
+	ctx.Next()
+}
+
+// SigningTransport is an http.RoundTripper signing every request for routes configured
+// with ConfigBuilder.SignedRequests
+type SigningTransport struct {
+	base    http.RoundTripper
+	alias   string
+	keyring *hmacKeyring
+	keyCtx  aruntime.Context
+}
+
+// NewSigningTransport creates a transport signing requests with the key alias of module.
+// Base nil uses http.DefaultTransport
+func NewSigningTransport(base http.RoundTripper, module security.SecureModule, alias string) *SigningTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &SigningTransport{
+		base:    base,
+		alias:   alias,
+		keyring: &hmacKeyring{module: module, ttl: defaultHmacKeyTTL},
+		keyCtx:  runtime.New(rlog.NewContextUUID(), txNoOp{}, tenant.NewEmpty()),
+	}
+}
+
+func (t *SigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, err := t.keyring.key(t.keyCtx, t.alias)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := req.Clone(req.Context())
+
+	var body []byte
+	if req.Body != nil {
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		signed.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := uuid.New().String()
+
+	message := CanonicalRequest(signed.Method, signed.URL.RequestURI(), timestamp, nonce, body,
+		signed.Header.Get("X-Tenant-ID"), signed.Header.Get("X-Tenant-UserID"))
+
+	signed.Header.Set(HeaderSignatureKey, t.alias)
+	signed.Header.Set(HeaderTimestamp, timestamp)
+	signed.Header.Set(HeaderNonce, nonce)
+	signed.Header.Set(HeaderSignature, base64.StdEncoding.EncodeToString(sign(key, message)))
+
+	return t.base.RoundTrip(signed)
+}
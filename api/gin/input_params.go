@@ -6,7 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/hellcats88/abstracte/api"
-	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/abstracte/logging"
 )
 
 type inputParamsHandler struct {
@@ -16,18 +16,19 @@ type inputParamsHandler struct {
 func (g inputParamsHandler) loadParams(ctx *gin.Context) {
 	params := make(map[string]string)
 
-	rCtx, _ := ctx.Get(api.RuntimeKey)
-	svcCtx := rCtx.(runtime.Context)
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
 
 	for _, p := range g.requestedInputParams {
 		pV := ctx.Param(p)
 		if pV == "" {
-			ctx.AbortWithStatusJSON(http.StatusNotFound, api.Model{
+			abortWithError(ctx, http.StatusNotFound, api.Model{
 				Error: api.ErrorModel{
 					Code:   api.ApiErrorMissingRequiredItem,
 					Msg:    "Missing part of URL",
 					DevMsg: fmt.Sprintf("Cannot find parameter %s", p),
-					CorrId: svcCtx.Log().CorrID(),
+					CorrId: logCtx.CorrID(),
 				},
 			})
 		}
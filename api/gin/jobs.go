@@ -0,0 +1,454 @@
+package gin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	aruntime "github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/abstracte/storage"
+	"github.com/hellcats88/abstracte/tenant"
+	"github.com/hellcats88/rem/runtime"
+)
+
+// JobStatus is the state of an asynchronous job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// ErrJobNotFound is returned by a JobStore when the job does not exist for the tenant
+var ErrJobNotFound = errors.New("Job not found")
+
+// Job is an asynchronous execution of a route service
+type Job struct {
+	ID         string          `json:"id"`
+	TenantID   string          `json:"tenantId"`
+	UserID     string          `json:"userId"`
+	Route      string          `json:"route"`
+	Status     JobStatus       `json:"status"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	Error      *api.ErrorModel `json:"error,omitempty"`
+
+	// JSON response model of the service, set when the job succeeded
+	Result json.RawMessage `json:"-"`
+}
+
+// JobStore persists the jobs. Jobs are always looked up within the tenant that created them
+type JobStore interface {
+	// Save creates or updates job
+	Save(job Job) error
+
+	// Find returns the job id of tenantID, or ErrJobNotFound
+	Find(tenantID string, id string) (Job, error)
+}
+
+// MemoryJobStore is an in memory JobStore, safe for concurrent use
+type MemoryJobStore struct {
+	mu        sync.Mutex
+	jobs      map[string]Job
+	retention time.Duration
+}
+
+// NewMemoryJobStore creates an in memory store forgetting the finished jobs after retention.
+// Zero retention keeps them forever
+func NewMemoryJobStore(retention time.Duration) *MemoryJobStore {
+	return &MemoryJobStore{jobs: make(map[string]Job), retention: retention}
+}
+
+func (s *MemoryJobStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.retention > 0 {
+		expired := time.Now().Add(-s.retention)
+		for key, j := range s.jobs {
+			if j.FinishedAt != nil && j.FinishedAt.Before(expired) {
+				delete(s.jobs, key)
+			}
+		}
+	}
+
+	s.jobs[job.TenantID+"/"+job.ID] = job
+	return nil
+}
+
+func (s *MemoryJobStore) Find(tenantID string, id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, exist := s.jobs[tenantID+"/"+id]
+	if !exist {
+		return Job{}, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// JobConfig defines the worker pool running the asynchronous routes
+type JobConfig struct {
+	// Storage opening the managed transaction of every job
+	Storage storage.Context
+
+	// Store of the jobs. Default: in memory store keeping finished jobs for 24 hours
+	Store JobStore
+
+	// Number of jobs running concurrently. Default: 4
+	Workers int
+
+	// Number of jobs waiting for a worker. Further requests are rejected with 503. Default: 100
+	QueueSize int
+}
+
+type asyncJob struct {
+	job     Job
+	method  string
+	tx      transactionHandler
+	service api.Service
+	input   jobServiceInput
+	logCtx  logging.Context
+	tenant  tenant.Context
+	runtime runtimeHandler
+}
+
+// errJobRunnerClosed is returned when submitting jobs after JobRunner.Close
+var errJobRunnerClosed = errors.New("Job runner closed")
+
+// JobRunner runs the services of the asynchronous routes on a bounded worker pool
+type JobRunner struct {
+	config     JobConfig
+	log        logging.Logger
+	queue      chan asyncJob
+	workers    sync.WaitGroup
+	statusPath string
+	mu         sync.RWMutex
+	closed     bool
+}
+
+// NewJobRunner starts the worker pool. A missing storage panics
+func NewJobRunner(log logging.Logger, config JobConfig) *JobRunner {
+	if config.Storage == nil {
+		panic("Job runner needs a storage context")
+	}
+
+	if config.Store == nil {
+		config.Store = NewMemoryJobStore(24 * time.Hour)
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+
+	runner := &JobRunner{config: config, log: log, queue: make(chan asyncJob, config.QueueSize)}
+
+	runner.workers.Add(config.Workers)
+	for i := 0; i < config.Workers; i++ {
+		go runner.work()
+	}
+
+	return runner
+}
+
+// Close stops accepting jobs and waits for the queued ones to complete
+func (r *JobRunner) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	r.workers.Wait()
+}
+
+// submit queues item, failing when the runner is closed or the queue is full
+func (r *JobRunner) submit(item asyncJob) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return errJobRunnerClosed
+	}
+
+	select {
+	case r.queue <- item:
+		return nil
+	default:
+		return fmt.Errorf("Job queue is full, %d jobs waiting", r.config.QueueSize)
+	}
+}
+
+// Job returns the job id of tenantID
+func (r *JobRunner) Job(tenantID string, id string) (Job, error) {
+	return r.config.Store.Find(tenantID, id)
+}
+
+func (r *JobRunner) work() {
+	defer r.workers.Done()
+
+	for item := range r.queue {
+		r.run(item)
+	}
+}
+
+func (r *JobRunner) save(item asyncJob) {
+	if err := r.config.Store.Save(item.job); err != nil {
+		r.log.Error(item.logCtx, "Failed to save job %s with status %s. %v", item.job.ID, item.job.Status, err)
+	}
+}
+
+func (r *JobRunner) fail(item asyncJob, code api.ApiError, msg string, err error) {
+	if err == nil {
+		err = errors.New(msg)
+	}
+
+	now := time.Now()
+	item.job.Status = JobFailed
+	item.job.FinishedAt = &now
+	item.job.Error = &api.ErrorModel{Code: code, Msg: msg, DevMsg: err.Error(), CorrId: item.logCtx.CorrID()}
+
+	r.log.Error(item.logCtx, "Job %s failed. %v", item.job.ID, err)
+	r.save(item)
+}
+
+// execute runs the service, turning panics into failed outputs so a worker never dies
+func (r *JobRunner) execute(rCtx aruntime.Context, item asyncJob) (output api.ServiceOutput) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			output = serviceOutput{status: api.ApiErrorUnexpected, err: fmt.Errorf("Service panic: %v", recovered), msg: "Unexpected error"}
+		}
+	}()
+
+	return item.service(rCtx, item.input)
+}
+
+func (r *JobRunner) run(item asyncJob) {
+	now := time.Now()
+	item.job.Status = JobRunning
+	item.job.StartedAt = &now
+	r.save(item)
+
+	r.log.Info(item.logCtx, "Job %s started for route %s", item.job.ID, item.job.Route)
+
+	open, cancel := item.tx.openerFor(context.Background(), item.method, true)
+	defer cancel()
+
+	tx, err := item.tx.open(context.Background(), item.logCtx, open)
+	if _, ok := err.(breakerOpenError); ok {
+		r.fail(item, ApiErrorUnavailable, "Service temporarily unavailable", fmt.Errorf("Circuit breaker %s is %s", item.tx.breaker.config.Name, item.tx.breaker.State()))
+		return
+	}
+
+	if err != nil {
+		r.fail(item, api.ApiErrorUnexpected, "Failed to open new managed transaction", err)
+		return
+	}
+
+	output := r.execute(runtime.NewWithFlags(item.logCtx, tx, item.tenant, item.runtime.env, item.runtime.features), item)
+
+	if output.Status() != api.ApiErrorNoError {
+		if err := tx.Rollback(); err != nil {
+			r.log.Error(item.logCtx, "Failed to rollback job %s. %v", item.job.ID, err)
+		}
+
+		r.fail(item, output.Status(), output.ErrMessage(), output.Err())
+		return
+	}
+
+	result, err := json.Marshal(output.ResponseModel())
+	if err != nil {
+		tx.Rollback()
+		r.fail(item, api.ApiErrorUnexpected, "Failed to serialize job result", err)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		r.fail(item, api.ApiErrorUnexpected, "Failed to commit changes", err)
+		return
+	}
+
+	finished := time.Now()
+	item.job.Status = JobSucceeded
+	item.job.FinishedAt = &finished
+	item.job.Result = result
+
+	r.log.Info(item.logCtx, "Job %s completed in %v", item.job.ID, finished.Sub(*item.job.StartedAt))
+	r.save(item)
+}
+
+// jobServiceInput is the ServiceInput of a job. The gin context is recycled once the
+// request is answered, so the bound inputs are copied when the job is queued
+type jobServiceInput struct {
+	values map[string]interface{}
+}
+
+func newJobServiceInput(ctx *gin.Context) jobServiceInput {
+	input := jobServiceInput{values: make(map[string]interface{})}
+
+	for _, key := range []string{api.InputModelKey, api.InputParamsKey, api.QueryParamsKey, api.HeadersModelKey, TypedParamsKey} {
+		if value, exist := ctx.Get(key); exist {
+			input.values[key] = value
+		}
+	}
+
+	return input
+}
+
+func (g jobServiceInput) get(key string, name string) interface{} {
+	value, ok := g.values[key]
+	if !ok {
+		panic(fmt.Sprintf("Missing required %s. Is pipeline correct?", name))
+	}
+
+	return value
+}
+
+// RawCtx returns nil, the request is already answered when the job runs
+func (g jobServiceInput) RawCtx() interface{} {
+	return nil
+}
+
+func (g jobServiceInput) Model() interface{} {
+	return g.get(api.InputModelKey, "Input Model")
+}
+
+func (g jobServiceInput) InputParams() map[string]string {
+	return g.get(api.InputParamsKey, "Input Params").(map[string]string)
+}
+
+func (g jobServiceInput) QueryParams() interface{} {
+	return g.get(api.QueryParamsKey, "Query Params")
+}
+
+func (g jobServiceInput) Headers() interface{} {
+	return g.get(api.HeadersModelKey, "Headers")
+}
+
+func (g jobServiceInput) TypedParams() interface{} {
+	return g.get(TypedParamsKey, "Typed Params")
+}
+
+type asyncHandler struct {
+	runner  *JobRunner
+	tx      transactionHandler
+	service api.Service
+	runtime runtimeHandler
+	log     logging.Logger
+}
+
+func (g asyncHandler) enqueue(ctx *gin.Context) {
+	// logging and tenant keys are always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	iTenantCtx, _ := ctx.Get(api.TenantKey)
+	tenantCtx := iTenantCtx.(tenant.Context)
+
+	item := asyncJob{
+		job: Job{
+			ID:        uuid.New().String(),
+			TenantID:  tenantCtx.ID(),
+			UserID:    tenantCtx.UserID(),
+			Route:     ctx.Request.Method + " " + ctx.FullPath(),
+			Status:    JobQueued,
+			CreatedAt: time.Now(),
+		},
+		method:  ctx.Request.Method,
+		tx:      g.tx,
+		service: g.service,
+		input:   newJobServiceInput(ctx),
+		logCtx:  logCtx,
+		tenant:  tenantCtx,
+		runtime: g.runtime,
+	}
+
+	if err := g.runner.config.Store.Save(item.job); err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Failed to create job",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	if err := g.runner.submit(item); err != nil {
+		msg := "Too many pending jobs"
+		if err == errJobRunnerClosed {
+			msg = "Service shutting down"
+		}
+
+		g.runner.fail(item, api.ApiErrorUnexpected, msg, err)
+
+		ctx.Header("Retry-After", "30")
+		abortWithError(ctx, http.StatusServiceUnavailable, api.Model{
+			Error: api.ErrorModel{
+				Code:   ApiErrorUnavailable,
+				Msg:    msg,
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	if g.runner.statusPath != "" {
+		ctx.Header("Location", g.runner.statusPath+"/"+item.job.ID)
+	}
+
+	ctx.JSON(http.StatusAccepted, api.Model{
+		Error: api.ErrorModel{
+			Code:   api.ApiErrorNoError,
+			CorrId: logCtx.CorrID(),
+		},
+		Data: item.job,
+	})
+}
+
+// jobService looks up the job of the path parameter id within the caller tenant
+func jobService(runner *JobRunner, result bool) api.Service {
+	return func(ctx aruntime.Context, input api.ServiceInput) api.ServiceOutput {
+		id := input.RawCtx().(*gin.Context).Param("id")
+
+		job, err := runner.Job(ctx.Tenant().ID(), id)
+		if errors.Is(err, ErrJobNotFound) {
+			return serviceOutput{status: api.ApiErrorEntityDoesNotExists, err: err, msg: "Job not found"}
+		}
+
+		if err != nil {
+			return serviceOutput{status: api.ApiErrorUnexpected, err: err, msg: "Failed to get job"}
+		}
+
+		if !result {
+			return serviceOutput{status: api.ApiErrorNoError, data: job}
+		}
+
+		switch job.Status {
+		case JobSucceeded:
+			return serviceOutput{status: api.ApiErrorNoError, data: job.Result}
+		case JobFailed:
+			return serviceOutput{status: job.Error.Code, err: errors.New(job.Error.DevMsg), msg: job.Error.Msg}
+		}
+
+		return serviceOutput{status: ApiErrorConflict, err: fmt.Errorf("Job %s is %s", job.ID, job.Status), msg: "Job not completed yet"}
+	}
+}
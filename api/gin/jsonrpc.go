@@ -0,0 +1,294 @@
+package gin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	aruntime "github.com/hellcats88/abstracte/runtime"
+	atenant "github.com/hellcats88/abstracte/tenant"
+	"github.com/hellcats88/rem/runtime"
+	"github.com/hellcats88/rem/tenant"
+)
+
+// JSON-RPC 2.0 error codes. Failed services are reported as server errors with the
+// api.ErrorModel as data. Their code is JsonRpcServerError - api.ApiError for the api errors,
+// JsonRpcServerError - 50 - (api.ApiError - ApiErrorConflict) for the errors of this package,
+// always kept inside the reserved range down to JsonRpcServerErrorMin
+const (
+	JsonRpcParseError     = -32700
+	JsonRpcInvalidRequest = -32600
+	JsonRpcMethodNotFound = -32601
+	JsonRpcInvalidParams  = -32602
+	JsonRpcInternalError  = -32603
+	JsonRpcServerError    = -32000
+	JsonRpcServerErrorMin = -32099
+)
+
+// jsonRpcServerCode maps a service error to its code in the JSON-RPC server errors range
+func jsonRpcServerCode(status api.ApiError) int {
+	offset := int(status)
+	if status >= ApiErrorConflict {
+		offset = 50 + int(status-ApiErrorConflict)
+	}
+
+	if code := JsonRpcServerError - offset; code > JsonRpcServerErrorMin {
+		return code
+	}
+	return JsonRpcServerErrorMin
+}
+
+// JsonRpcConfig defines the pipeline of a JSON-RPC method
+type JsonRpcConfig struct {
+	// Zero value of the params model. Nil means the method has no params
+	Params interface{}
+
+	// Tenant source, ConfigTenantNo or ConfigTenantFromHeaders reading the tenant from the HTTP request headers
+	Tenant api.ConfigTenant
+}
+
+// JsonRpcError is the error object of a JSON-RPC response
+type JsonRpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type jsonRpcRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonRpcResponse struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JsonRpcError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonRpcMethod struct {
+	config  JsonRpcConfig
+	service api.Service
+}
+
+// execute runs the service, turning a panic into an error so that it fails only its own
+// call and not the whole batch
+func (m jsonRpcMethod) execute(rCtx aruntime.Context, input jsonRpcServiceInput) (output api.ServiceOutput, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("Service panic: %v", recovered)
+		}
+	}()
+
+	return m.service(rCtx, input), nil
+}
+
+type jsonRpcServiceInput struct {
+	ctx    *gin.Context
+	params interface{}
+}
+
+func (j jsonRpcServiceInput) RawCtx() interface{} {
+	return j.ctx
+}
+
+func (j jsonRpcServiceInput) Model() interface{} {
+	if j.params == nil {
+		panic("Missing required Params. Is method config correct?")
+	}
+
+	return j.params
+}
+
+func (j jsonRpcServiceInput) InputParams() map[string]string {
+	return map[string]string{}
+}
+
+func (j jsonRpcServiceInput) QueryParams() interface{} {
+	return nil
+}
+
+func (j jsonRpcServiceInput) Headers() interface{} {
+	return nil
+}
+
+// JsonRpc dispatches the calls received on a single endpoint to the registered services
+type JsonRpc struct {
+	log     logging.Logger
+	methods map[string]jsonRpcMethod
+}
+
+// NewJsonRpc creates an empty JSON-RPC dispatcher
+func NewJsonRpc(log logging.Logger) *JsonRpc {
+	return &JsonRpc{
+		log:     log,
+		methods: make(map[string]jsonRpcMethod),
+	}
+}
+
+// Register binds a JSON-RPC method name to a service. Registering the same name twice replaces it
+func (j *JsonRpc) Register(method string, config JsonRpcConfig, service api.Service) {
+	if config.Tenant != api.ConfigTenantNo && config.Tenant != api.ConfigTenantFromHeaders {
+		panic(fmt.Sprintf("Unsupported tenant source %d for JSON-RPC method %s", config.Tenant, method))
+	}
+
+	j.methods[method] = jsonRpcMethod{config: config, service: service}
+}
+
+func rpcError(id json.RawMessage, code int, msg string, data interface{}) *jsonRpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+
+	return &jsonRpcResponse{
+		JsonRpc: "2.0",
+		Error:   &JsonRpcError{Code: code, Message: msg, Data: data},
+		ID:      id,
+	}
+}
+
+// handler serves the JSON-RPC requests, building the runtime contexts of the methods with rt
+func (j *JsonRpc) handler(rt runtimeHandler) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		j.handle(ctx, rt)
+	}
+}
+
+func (j *JsonRpc) handle(ctx *gin.Context, rt runtimeHandler) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil || !json.Valid(body) {
+		ctx.JSON(http.StatusOK, rpcError(nil, JsonRpcParseError, "Parse error", nil))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		if res := j.call(ctx, rt, logCtx, body); res != nil {
+			ctx.JSON(http.StatusOK, res)
+		} else {
+			ctx.Status(http.StatusNoContent)
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil || len(batch) == 0 {
+		ctx.JSON(http.StatusOK, rpcError(nil, JsonRpcInvalidRequest, "Invalid Request", nil))
+		return
+	}
+
+	responses := make([]*jsonRpcResponse, 0, len(batch))
+	for _, raw := range batch {
+		if res := j.call(ctx, rt, logCtx, raw); res != nil {
+			responses = append(responses, res)
+		}
+	}
+
+	// a batch of notifications has no response at all
+	if len(responses) == 0 {
+		ctx.Status(http.StatusNoContent)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, responses)
+}
+
+// call runs a single JSON-RPC request. A nil response means the request is a notification
+func (j *JsonRpc) call(ctx *gin.Context, rt runtimeHandler, baseLogCtx logging.Context, raw json.RawMessage) *jsonRpcResponse {
+	var req jsonRpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JsonRpc != "2.0" || req.Method == "" {
+		return rpcError(req.ID, JsonRpcInvalidRequest, "Invalid Request", nil)
+	}
+
+	notification := len(req.ID) == 0
+	respond := func(res *jsonRpcResponse) *jsonRpcResponse {
+		if notification {
+			return nil
+		}
+		return res
+	}
+
+	logCtx := baseLogCtx.Clone()
+	logCtx.AddExtra(logging.K{N: "rpcMethod", V: req.Method}, logging.K{N: "rpcId", V: string(req.ID)})
+
+	method, exist := j.methods[req.Method]
+	if !exist {
+		j.log.Warn(logCtx, "Requested unknown JSON-RPC method %s", req.Method)
+		return respond(rpcError(req.ID, JsonRpcMethodNotFound, "Method not found", nil))
+	}
+
+	var params interface{}
+	if method.config.Params != nil {
+		params = reflect.New(reflect.TypeOf(method.config.Params)).Interface()
+		if len(req.Params) == 0 {
+			return respond(rpcError(req.ID, JsonRpcInvalidParams, "Invalid params", "Missing params"))
+		}
+
+		if err := json.Unmarshal(req.Params, params); err != nil {
+			return respond(rpcError(req.ID, JsonRpcInvalidParams, "Invalid params", err.Error()))
+		}
+	}
+
+	var tCtx atenant.Context
+	if method.config.Tenant == api.ConfigTenantFromHeaders {
+		tCtx = tenant.New(ctx.GetHeader("X-Tenant-ID"), ctx.GetHeader("X-Tenant-UserID"))
+
+		if tCtx.ID() == "" || tCtx.UserID() == "" {
+			j.log.Error(logCtx, "Rejected call caused by missing tenant informations")
+			return respond(rpcError(req.ID, jsonRpcServerCode(api.ApiErrorAuthFailed), "Failed to get user information", api.ErrorModel{
+				Code:   api.ApiErrorAuthFailed,
+				Msg:    "Failed to get user information",
+				DevMsg: "Missing X-Tenant-ID or X-Tenant-UserID headers",
+				CorrId: logCtx.CorrID(),
+			}))
+		}
+	} else {
+		tCtx = tenant.NewEmpty()
+	}
+
+	rCtx := runtime.NewWithFlags(logCtx, txNoOp{}, tCtx, rt.env, rt.features)
+	svcRes, err := method.execute(rCtx, jsonRpcServiceInput{ctx: ctx, params: params})
+	if err != nil {
+		j.log.Error(logCtx, "JSON-RPC method %s failed. %v", req.Method, err)
+		return respond(rpcError(req.ID, JsonRpcInternalError, "Internal error", api.ErrorModel{
+			Code:   api.ApiErrorUnexpected,
+			Msg:    "Unexpected error",
+			DevMsg: err.Error(),
+			CorrId: logCtx.CorrID(),
+		}))
+	}
+
+	if svcRes.Status() != api.ApiErrorNoError {
+		devMsg := ""
+		if svcRes.Err() != nil {
+			devMsg = svcRes.Err().Error()
+		}
+
+		return respond(rpcError(req.ID, jsonRpcServerCode(svcRes.Status()), svcRes.ErrMessage(), api.ErrorModel{
+			Code:   svcRes.Status(),
+			Msg:    svcRes.ErrMessage(),
+			DevMsg: devMsg,
+			CorrId: logCtx.CorrID(),
+		}))
+	}
+
+	result, err := json.Marshal(svcRes.ResponseModel())
+	if err != nil {
+		j.log.Error(logCtx, "Failed to serialize JSON-RPC result. %v", err)
+		return respond(rpcError(req.ID, JsonRpcInternalError, "Internal error", fmt.Sprintf("%v", err)))
+	}
+
+	return respond(&jsonRpcResponse{JsonRpc: "2.0", Result: result, ID: req.ID})
+}
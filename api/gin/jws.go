@@ -0,0 +1,266 @@
+package gin
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/security"
+	"github.com/hellcats88/rem/runtime"
+	"github.com/hellcats88/rem/tenant"
+)
+
+// ErrInvalidJws is returned by VerifyDetachedJws when the signature does not match the payload
+var ErrInvalidJws = errors.New("Invalid JWS signature")
+
+// JwsConfig defines the detached JWS signature of the responses
+type JwsConfig struct {
+	// Secure module holding the signing key
+	Module security.SecureModule
+
+	// Alias of the RSA, ECDSA or Ed25519 signing key
+	KeyAlias string
+
+	// Key ID published in the JWS header. Default: KeyAlias
+	KeyID string
+
+	// Response header carrying the signature. Default: X-Jws-Signature
+	Header string
+}
+
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// jwsAlgorithm returns the JWA name and the digest of the signatures made by key
+func jwsAlgorithm(key crypto.PublicKey) (string, crypto.Hash, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return "RS256", crypto.SHA256, nil
+
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return "ES256", crypto.SHA256, nil
+		case elliptic.P384():
+			return "ES384", crypto.SHA384, nil
+		case elliptic.P521():
+			return "ES512", crypto.SHA512, nil
+		}
+		return "", 0, fmt.Errorf("Unsupported ECDSA curve %s", k.Curve.Params().Name)
+
+	case ed25519.PublicKey:
+		return "EdDSA", 0, nil
+	}
+
+	return "", 0, fmt.Errorf("Unsupported signing key %T", key)
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	}
+	return data
+}
+
+func curveBytes(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+// SignDetachedJws signs payload returning the JWS compact serialization without the payload
+// part (header..signature), as defined by RFC 7515 appendix F
+func SignDetachedJws(signer crypto.Signer, kid string, payload []byte) (string, error) {
+	alg, hash, err := jwsAlgorithm(signer.Public())
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(jwsHeader{Alg: alg, Kid: kid})
+	if err != nil {
+		return "", err
+	}
+
+	encodedHeader := base64.RawURLEncoding.EncodeToString(header)
+	input := encodedHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature, err := signer.Sign(rand.Reader, digest(hash, []byte(input)), hash)
+	if err != nil {
+		return "", err
+	}
+
+	// JWS carries ECDSA signatures as fixed size R || S instead of ASN.1
+	if key, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &rs); err != nil {
+			return "", err
+		}
+
+		size := curveBytes(key.Curve)
+		signature = make([]byte, 2*size)
+		rs.R.FillBytes(signature[:size])
+		rs.S.FillBytes(signature[size:])
+	}
+
+	return encodedHeader + ".." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyDetachedJws checks the detached JWS signature of a response body with the public key of the server
+func VerifyDetachedJws(jws string, payload []byte, key crypto.PublicKey) error {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return fmt.Errorf("%w. Not a detached compact serialization", ErrInvalidJws)
+	}
+
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("%w. %v", ErrInvalidJws, err)
+	}
+
+	var header jwsHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return fmt.Errorf("%w. %v", ErrInvalidJws, err)
+	}
+
+	alg, hash, err := jwsAlgorithm(key)
+	if err != nil {
+		return err
+	}
+
+	// the algorithm comes from the trusted key, never from the header
+	if header.Alg != alg {
+		return fmt.Errorf("%w. Algorithm %s does not match the key", ErrInvalidJws, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w. %v", ErrInvalidJws, err)
+	}
+
+	input := []byte(parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload))
+
+	valid := false
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(k, hash, digest(hash, input), signature) == nil
+
+	case *ecdsa.PublicKey:
+		size := curveBytes(k.Curve)
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			valid = ecdsa.Verify(k, digest(hash, input), r, s)
+		}
+
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, input, signature)
+	}
+
+	if !valid {
+		return ErrInvalidJws
+	}
+
+	return nil
+}
+
+type jwsHandler struct {
+	config JwsConfig
+	log    logging.Logger
+	mu     *sync.Mutex
+	signer *crypto.Signer
+}
+
+func newJwsHandler(config JwsConfig, log logging.Logger) jwsHandler {
+	if config.KeyID == "" {
+		config.KeyID = config.KeyAlias
+	}
+
+	if config.Header == "" {
+		config.Header = "X-Jws-Signature"
+	}
+
+	return jwsHandler{config: config, log: log, mu: &sync.Mutex{}, signer: new(crypto.Signer)}
+}
+
+// loadSigner resolves the signer once, retrying on the next response after a failure
+func (g jwsHandler) loadSigner(logCtx logging.Context) (crypto.Signer, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if *g.signer != nil {
+		return *g.signer, nil
+	}
+
+	signer, err := g.config.Module.Signer(runtime.New(logCtx, txNoOp{}, tenant.NewEmpty()), g.config.KeyAlias)
+	if err != nil {
+		return nil, err
+	}
+
+	*g.signer = signer
+	return signer, nil
+}
+
+func (g jwsHandler) signResponse(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	writer := newBufferedWriter(ctx.Writer)
+	ctx.Writer = writer
+	ctx.Next()
+	ctx.Writer = writer.ResponseWriter
+
+	body := writer.Bytes()
+
+	signer, err := g.loadSigner(logCtx)
+	if err == nil {
+		var jws string
+		if jws, err = SignDetachedJws(signer, g.config.KeyID, body); err == nil {
+			ctx.Header(g.config.Header, jws)
+			writer.flush(body)
+			return
+		}
+	}
+
+	// partners reject unsigned responses, so the original one is never sent without signature
+	g.log.Error(logCtx, "Failed to sign response with key %s. %v", g.config.KeyAlias, err)
+
+	failure, _ := json.Marshal(api.Model{
+		Error: api.ErrorModel{
+			Code:   api.ApiErrorUnexpected,
+			Msg:    localizedMessage(ctx, api.ApiErrorUnexpected, "Failed to sign response"),
+			DevMsg: err.Error(),
+			CorrId: logCtx.CorrID(),
+		},
+	})
+
+	writer.status = http.StatusInternalServerError
+	ctx.Writer.Header().Del("Content-Length")
+	ctx.Header("Content-Type", "application/json; charset=utf-8")
+	writer.flush(failure)
+}
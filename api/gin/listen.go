@@ -0,0 +1,237 @@
+package gin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// first file descriptor passed by systemd socket activation
+const systemdFirstFD = 3
+
+// runningServers tracks the servers started by Serve, so that Shutdown can stop them
+type runningServers struct {
+	mu      sync.Mutex
+	servers []*http.Server
+}
+
+func (r *runningServers) add(server *http.Server) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.servers = append(r.servers, server)
+}
+
+func (r *runningServers) shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	servers := r.servers
+	r.servers = nil
+	r.mu.Unlock()
+
+	var first error
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// TCPListener opens a TCP listener on address:port
+func TCPListener(address string, port int) (net.Listener, error) {
+	return net.Listen("tcp", fmt.Sprintf("%s:%d", address, port))
+}
+
+// unixListener removes the socket from its final path on Close, the net package would
+// only remove the temporary path it has been bound to
+type unixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l unixListener) Addr() net.Addr {
+	return &net.UnixAddr{Name: l.path, Net: "unix"}
+}
+
+func (l unixListener) Close() error {
+	err := l.UnixListener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// UnixListener opens a Unix domain socket listener on path with the given permissions.
+// The socket is created in a private directory and moved to path once its permissions
+// are set, so that it is never reachable with wider ones. A socket left by a previous run
+// is replaced only if nobody answers on it, any other existing file is an error
+func UnixListener(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("Cannot listen on %s, file exists and it is not a socket", path)
+		}
+
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("Cannot listen on %s, socket already in use", path)
+		}
+	}
+
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".listen")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "socket")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmpPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	// rename replaces a stale socket atomically
+	if err := os.Rename(tmpPath, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return unixListener{UnixListener: listener, path: path}, nil
+}
+
+// SystemdListeners returns the listeners passed by systemd socket activation, in the order
+// of the socket unit. It returns no listener when the process was not socket activated
+func SystemdListeners() ([]net.Listener, error) {
+	listeners, _, err := systemdListeners()
+	return listeners, err
+}
+
+// SystemdNamedListeners returns the listeners passed by systemd socket activation grouped
+// by the FileDescriptorName of the socket units
+func SystemdNamedListeners() (map[string][]net.Listener, error) {
+	listeners, names, err := systemdListeners()
+	if err != nil {
+		return nil, err
+	}
+
+	named := make(map[string][]net.Listener)
+	for i, listener := range listeners {
+		named[names[i]] = append(named[names[i]], listener)
+	}
+	return named, nil
+}
+
+func systemdListeners() ([]net.Listener, []string, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil, nil
+	}
+
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil, nil
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// the sockets belong to this process only, children must not inherit them
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+	listenerNames := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		file := os.NewFile(uintptr(systemdFirstFD+i), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, nil, fmt.Errorf("Invalid systemd socket %d (%s). %v", systemdFirstFD+i, name, err)
+		}
+
+		listeners = append(listeners, listener)
+		listenerNames = append(listenerNames, name)
+	}
+
+	return listeners, listenerNames, nil
+}
+
+func (g ginHttp) Serve(listeners ...net.Listener) error {
+	if len(listeners) == 0 {
+		return errors.New("Serve needs at least one listener")
+	}
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		server := g.server(listener.Addr().String())
+		g.running.add(server)
+
+		go func(server *http.Server, listener net.Listener) {
+			errs <- server.Serve(listener)
+		}(server, listener)
+	}
+
+	// the first listener failing stops the others, so the process never serves partially
+	err := <-errs
+	if !errors.Is(err, http.ErrServerClosed) {
+		g.running.shutdown(context.Background())
+	}
+
+	for i := 1; i < len(listeners); i++ {
+		<-errs
+	}
+
+	return err
+}
+
+func (g ginHttp) Shutdown(ctx context.Context) error {
+	return g.running.shutdown(ctx)
+}
+
+// Binding pairs an Http with the listeners serving it, e.g. the public and the admin APIs
+type Binding struct {
+	Http      Http
+	Listeners []net.Listener
+}
+
+// ServeAll serves every binding simultaneously. When one of them stops with an error the
+// others are shut down, and the error is returned
+func ServeAll(bindings ...Binding) error {
+	errs := make(chan error, len(bindings))
+	for _, binding := range bindings {
+		go func(binding Binding) {
+			errs <- binding.Http.Serve(binding.Listeners...)
+		}(binding)
+	}
+
+	err := <-errs
+	for _, binding := range bindings {
+		binding.Http.Shutdown(context.Background())
+	}
+
+	for i := 1; i < len(bindings); i++ {
+		<-errs
+	}
+
+	return err
+}
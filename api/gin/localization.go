@@ -0,0 +1,191 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/tenant"
+)
+
+// localizerKey stores in the gin context the localization of the request
+const localizerKey = "_rem_gin_localizer_key"
+
+// ErrorCodeKey is the catalog key translating every message of an error code
+// that has no translation of its own
+func ErrorCodeKey(code api.ApiError) string {
+	return fmt.Sprintf("code:%d", code)
+}
+
+// Catalog holds the translations of the error messages by locale. Messages are keyed by
+// their English text, as returned by services and pipeline stages, or by ErrorCodeKey.
+// Messages without translation are sent in English
+type Catalog struct {
+	mu       sync.RWMutex
+	messages map[string]map[string]string
+}
+
+// NewCatalog creates an empty catalog
+func NewCatalog() *Catalog {
+	return &Catalog{messages: make(map[string]map[string]string)}
+}
+
+// LoadCatalog reads a JSON file mapping locales to objects of English message to translation
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var locales map[string]map[string]string
+	if err := json.Unmarshal(data, &locales); err != nil {
+		return nil, fmt.Errorf("Invalid catalog file %s. %v", path, err)
+	}
+
+	catalog := NewCatalog()
+	for locale, messages := range locales {
+		catalog.Add(locale, messages)
+	}
+	return catalog, nil
+}
+
+// Add registers the translations of messages for locale, e.g. "it" or "pt-BR"
+func (c *Catalog) Add(locale string, messages map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	locale = strings.ToLower(locale)
+	if c.messages[locale] == nil {
+		c.messages[locale] = make(map[string]string)
+	}
+
+	for key, translation := range messages {
+		c.messages[locale][key] = translation
+	}
+}
+
+// lookup translates msg for the first supported locale. Regional locales fall back on their language
+func (c *Catalog) lookup(locales []string, code api.ApiError, msg string) (string, string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, locale := range locales {
+		candidates := []string{locale}
+		if i := strings.IndexByte(locale, '-'); i > 0 {
+			candidates = append(candidates, locale[:i])
+		}
+
+		for _, candidate := range candidates {
+			messages, exist := c.messages[candidate]
+			if !exist {
+				continue
+			}
+
+			if translation, exist := messages[msg]; exist {
+				return translation, candidate, true
+			}
+
+			if translation, exist := messages[ErrorCodeKey(code)]; exist {
+				return translation, candidate, true
+			}
+		}
+	}
+
+	return msg, "", false
+}
+
+// LocalizationConfig enables the translation of the error messages of all the routes
+type LocalizationConfig struct {
+	// Translations of the messages
+	Catalog *Catalog
+
+	// Returns the locale of the tenant, used when Accept-Language has no supported locale. Optional
+	TenantLocale func(tenantID string) string
+}
+
+// acceptedLanguages returns the locales of an Accept-Language header by decreasing preference
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+
+	var accepted []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		locale := strings.ToLower(strings.TrimSpace(fields[0]))
+		if locale == "" || locale == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+
+		if q > 0 {
+			accepted = append(accepted, weighted{locale: locale, q: q})
+		}
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+
+	locales := make([]string, len(accepted))
+	for i, a := range accepted {
+		locales[i] = a.locale
+	}
+	return locales
+}
+
+type localizationHandler struct {
+	config LocalizationConfig
+}
+
+func (g localizationHandler) setLocalizer(ctx *gin.Context) {
+	ctx.Set(localizerKey, g)
+	ctx.Next()
+}
+
+// translate localizes msg for the request. The tenant, when already resolved, provides the default locale
+func (g localizationHandler) translate(ctx *gin.Context, code api.ApiError, msg string) string {
+	locales := acceptedLanguages(ctx.GetHeader("Accept-Language"))
+
+	if g.config.TenantLocale != nil {
+		if iTenantCtx, exist := ctx.Get(api.TenantKey); exist {
+			if locale := g.config.TenantLocale(iTenantCtx.(tenant.Context).ID()); locale != "" {
+				locales = append(locales, strings.ToLower(locale))
+			}
+		}
+	}
+
+	translation, locale, found := g.config.Catalog.lookup(locales, code, msg)
+	if found {
+		ctx.Header("Content-Language", locale)
+	}
+	return translation
+}
+
+// localizedMessage translates msg when the route has localization enabled
+func localizedMessage(ctx *gin.Context, code api.ApiError, msg string) string {
+	if localizer, exist := ctx.Get(localizerKey); exist {
+		return localizer.(localizationHandler).translate(ctx, code, msg)
+	}
+	return msg
+}
+
+// abortWithError stops the pipeline answering with the error envelope model, translating its message
+func abortWithError(ctx *gin.Context, status int, model api.Model) {
+	model.Error.Msg = localizedMessage(ctx, model.Error.Code, model.Error.Msg)
+	ctx.AbortWithStatusJSON(status, model)
+}
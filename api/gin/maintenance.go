@@ -0,0 +1,161 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/tenant"
+)
+
+// MaintenanceMode defines which requests are rejected during a maintenance
+type MaintenanceMode int
+
+const (
+	// MaintenanceOff serves every request
+	MaintenanceOff MaintenanceMode = iota
+
+	// MaintenanceReadOnly rejects the requests with a mutating method and the routes
+	// using managed transactions, while the other reads keep working
+	MaintenanceReadOnly
+
+	// MaintenanceFull rejects every request
+	MaintenanceFull
+)
+
+func (m MaintenanceMode) String() string {
+	switch m {
+	case MaintenanceOff:
+		return "off"
+	case MaintenanceReadOnly:
+		return "read-only"
+	case MaintenanceFull:
+		return "full"
+	}
+	return fmt.Sprintf("MaintenanceMode(%d)", int(m))
+}
+
+// Maintenance describes a maintenance window
+type Maintenance struct {
+	Mode MaintenanceMode
+
+	// Sent in the Retry-After header of the rejected requests. Default: 60 seconds
+	RetryAfter time.Duration
+
+	// Message of the rejected requests. Default: Service under maintenance
+	Message string
+}
+
+// MaintenanceScope selects the requests affected by a maintenance. The zero value is global,
+// Env restricts it to the servers of an environment and TenantID to a single tenant
+type MaintenanceScope struct {
+	Env      string
+	TenantID string
+}
+
+// MaintenanceSwitch toggles the maintenance at runtime. It can be shared by several servers
+// through ServerOptions.Maintenance and it is safe for concurrent use
+type MaintenanceSwitch struct {
+	mu     sync.RWMutex
+	scopes map[MaintenanceScope]Maintenance
+}
+
+// NewMaintenanceSwitch creates a switch with the maintenance off everywhere
+func NewMaintenanceSwitch() *MaintenanceSwitch {
+	return &MaintenanceSwitch{scopes: make(map[MaintenanceScope]Maintenance)}
+}
+
+// Set starts a maintenance on scope, replacing the previous one of the same scope.
+// A MaintenanceOff mode ends it
+func (s *MaintenanceSwitch) Set(scope MaintenanceScope, maintenance Maintenance) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if maintenance.Mode == MaintenanceOff {
+		delete(s.scopes, scope)
+		return
+	}
+
+	s.scopes[scope] = maintenance
+}
+
+// Clear ends the maintenance on scope
+func (s *MaintenanceSwitch) Clear(scope MaintenanceScope) {
+	s.Set(scope, Maintenance{})
+}
+
+// Lookup returns the strictest maintenance applying to a tenant of an environment
+func (s *MaintenanceSwitch) Lookup(env string, tenantID string) Maintenance {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result Maintenance
+	for _, scope := range []MaintenanceScope{{}, {Env: env}, {TenantID: tenantID}, {Env: env, TenantID: tenantID}} {
+		if maintenance, exist := s.scopes[scope]; exist && maintenance.Mode > result.Mode {
+			result = maintenance
+		}
+	}
+
+	return result
+}
+
+type maintenanceHandler struct {
+	maintenance *MaintenanceSwitch
+	env         string
+	writes      bool
+	log         logging.Logger
+}
+
+// isWrite reports if a route is rejected in read-only mode
+func isWrite(method string, tx string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return tx == RouteModeManaged
+	}
+	return true
+}
+
+func (g maintenanceHandler) checkMaintenance(ctx *gin.Context) {
+	// logging and tenant keys are always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	iTenantCtx, _ := ctx.Get(api.TenantKey)
+	tenantCtx := iTenantCtx.(tenant.Context)
+
+	maintenance := g.maintenance.Lookup(g.env, tenantCtx.ID())
+	if maintenance.Mode == MaintenanceOff || (maintenance.Mode == MaintenanceReadOnly && !g.writes) {
+		ctx.Next()
+		return
+	}
+
+	if maintenance.RetryAfter <= 0 {
+		maintenance.RetryAfter = time.Minute
+	}
+
+	if maintenance.Message == "" {
+		maintenance.Message = "Service under maintenance"
+	}
+
+	g.log.Debug(logCtx, "Rejected call to %s %s of tenant %s during maintenance",
+		ctx.Request.Method, ctx.FullPath(), tenantCtx.ID())
+
+	ctx.Header("Retry-After", strconv.Itoa(int(maintenance.RetryAfter.Round(time.Second)/time.Second)))
+	abortWithError(ctx, http.StatusServiceUnavailable, api.Model{
+		Error: api.ErrorModel{
+			Code:   ApiErrorUnavailable,
+			Msg:    maintenance.Message,
+			DevMsg: fmt.Sprintf("%s maintenance in progress", maintenance.Mode),
+			CorrId: logCtx.CorrID(),
+		},
+	})
+}
+
+func (g ginHttp) Maintenance() *MaintenanceSwitch {
+	return g.maintenance
+}
@@ -7,7 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/hellcats88/abstracte/api"
-	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/abstracte/logging"
 )
 
 type modelHandler struct {
@@ -17,16 +17,17 @@ type modelHandler struct {
 func (g modelHandler) getModel(ctx *gin.Context) {
 	emptyModel := reflect.New(reflect.TypeOf(g.requestedModel)).Interface()
 
-	rCtx, _ := ctx.Get(api.RuntimeKey)
-	svcCtx := rCtx.(runtime.Context)
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
 
 	if err := ctx.ShouldBindJSON(emptyModel); err != nil {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, api.Model{
+		abortWithError(ctx, http.StatusBadRequest, api.Model{
 			Error: api.ErrorModel{
 				Code:   api.ApiErrorUnexpected,
 				Msg:    "API needs a valid payload",
 				DevMsg: fmt.Sprintf("Failed to transform payload model from JSON. %v", err),
-				CorrId: svcCtx.Log().CorrID(),
+				CorrId: logCtx.CorrID(),
 			},
 		})
 	}
@@ -0,0 +1,635 @@
+package gin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+)
+
+var (
+	// ErrPatchInvalidOperation means the patch document contains a malformed operation
+	ErrPatchInvalidOperation = errors.New("Invalid patch operation")
+
+	// ErrPatchInvalidPath means an operation path does not exist in the target entity
+	ErrPatchInvalidPath = errors.New("Invalid patch path")
+
+	// ErrPatchTestFailed means a test operation did not match the target entity
+	ErrPatchTestFailed = errors.New("Patch test operation failed")
+)
+
+// PatchError describes the operation that made a patch fail. It wraps one of the ErrPatch errors
+type PatchError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("%v: %s %s", e.Err, e.Op, e.Path)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// PatchErrorStatus maps an error returned by Patch.Apply into the service status
+func PatchErrorStatus(err error) api.ApiError {
+	switch {
+	case errors.Is(err, ErrPatchTestFailed):
+		return ApiErrorConflict
+	case errors.Is(err, ErrPatchInvalidPath):
+		return api.ApiErrorUnknownItemRequested
+	case errors.Is(err, ErrPatchInvalidOperation):
+		return api.ApiErrorMissingRequiredItem
+	}
+
+	return api.ApiErrorUnexpected
+}
+
+// NewPatchErrorOutput creates the service output reporting an error returned by Patch.Apply
+func NewPatchErrorOutput(err error) api.ServiceOutput {
+	return serviceOutput{status: PatchErrorStatus(err), err: err, msg: "Failed to apply patch"}
+}
+
+// Patch is the input model of routes configured with JsonPatch or MergePatch
+type Patch interface {
+	// Apply patches target, a pointer to a JSON serializable entity. The fields hidden
+	// from JSON keep their value. On error target is left unchanged
+	Apply(target interface{}) error
+
+	// ApplyJSON patches a JSON document
+	ApplyJSON(doc []byte) ([]byte, error)
+}
+
+func decodeDocument(doc []byte) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func applyToEntity(p Patch, target interface{}) error {
+	doc, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+
+	patched, err := p.ApplyJSON(doc)
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return errors.New("Patch target must be a non nil pointer")
+	}
+
+	// decode into a copy first so that target is untouched on failure. The copy keeps the
+	// fields hidden from JSON (json:"-" and unexported ones), the visible ones are reset so
+	// that the members removed by the patch do not survive
+	fresh := reflect.New(value.Elem().Type())
+	fresh.Elem().Set(value.Elem())
+	resetJSONFields(fresh.Elem())
+
+	if err := json.Unmarshal(patched, fresh.Interface()); err != nil {
+		return err
+	}
+
+	value.Elem().Set(fresh.Elem())
+	return nil
+}
+
+// resetJSONFields zeroes the fields of v serialized to JSON, descending into nested structs
+func resetJSONFields(v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		if v.CanSet() {
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+
+		// unexported fields are hidden from JSON, except the embedded structs promoting theirs
+		if field.PkgPath != "" && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		resetJSONFields(v.Field(i))
+	}
+}
+
+// PatchOperation is a single RFC 6902 operation
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+
+	value interface{}
+	path  []string
+	from  []string
+}
+
+// JsonPatch is a validated RFC 6902 JSON Patch document
+type JsonPatch struct {
+	Operations []PatchOperation
+}
+
+// MergePatch is a validated RFC 7396 JSON Merge Patch document
+type MergePatch struct {
+	document interface{}
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, ErrPatchInvalidOperation
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+// ParseJsonPatch validates an RFC 6902 document
+func ParseJsonPatch(doc []byte) (*JsonPatch, error) {
+	var ops []PatchOperation
+	if err := json.Unmarshal(doc, &ops); err != nil {
+		return nil, &PatchError{Err: ErrPatchInvalidOperation, Op: "parse", Path: err.Error()}
+	}
+
+	for i := range ops {
+		op := &ops[i]
+		invalid := &PatchError{Op: op.Op, Path: op.Path, Err: ErrPatchInvalidOperation}
+
+		path, err := parsePointer(op.Path)
+		if err != nil {
+			return nil, invalid
+		}
+		op.path = path
+
+		switch op.Op {
+		case "add", "replace", "test":
+			if len(op.Value) == 0 {
+				return nil, invalid
+			}
+
+			value, err := decodeDocument(op.Value)
+			if err != nil {
+				return nil, invalid
+			}
+			op.value = value
+
+		case "move", "copy":
+			from, err := parsePointer(op.From)
+			if err != nil {
+				return nil, invalid
+			}
+			op.from = from
+
+			if op.Op == "move" && strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, invalid
+			}
+
+		case "remove":
+
+		default:
+			return nil, invalid
+		}
+	}
+
+	return &JsonPatch{Operations: ops}, nil
+}
+
+// ParseMergePatch validates an RFC 7396 document
+func ParseMergePatch(doc []byte) (*MergePatch, error) {
+	document, err := decodeDocument(doc)
+	if err != nil {
+		return nil, &PatchError{Err: ErrPatchInvalidOperation, Op: "parse", Path: err.Error()}
+	}
+
+	return &MergePatch{document: document}, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, bool) {
+	if allowEnd && token == "-" {
+		return length, true
+	}
+
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return 0, false
+	}
+
+	if idx > length || (!allowEnd && idx == length) {
+		return 0, false
+	}
+
+	return idx, true
+}
+
+func getValue(node interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, exist := n[token]
+			if !exist {
+				return nil, false
+			}
+			node = child
+
+		case []interface{}:
+			idx, ok := arrayIndex(token, len(n), false)
+			if !ok {
+				return nil, false
+			}
+			node = n[idx]
+
+		default:
+			return nil, false
+		}
+	}
+
+	return node, true
+}
+
+// setValue adds (or replaces when replace is true) value at tokens, returning the updated node
+func setValue(node interface{}, tokens []string, value interface{}, replace bool) (interface{}, bool) {
+	if len(tokens) == 0 {
+		return value, true
+	}
+
+	token := tokens[0]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(tokens) == 1 {
+			if _, exist := n[token]; replace && !exist {
+				return nil, false
+			}
+			n[token] = value
+			return n, true
+		}
+
+		child, exist := n[token]
+		if !exist {
+			return nil, false
+		}
+
+		updated, ok := setValue(child, tokens[1:], value, replace)
+		if !ok {
+			return nil, false
+		}
+		n[token] = updated
+		return n, true
+
+	case []interface{}:
+		if len(tokens) == 1 {
+			idx, ok := arrayIndex(token, len(n), !replace)
+			if !ok {
+				return nil, false
+			}
+
+			if replace {
+				n[idx] = value
+				return n, true
+			}
+
+			n = append(n, nil)
+			copy(n[idx+1:], n[idx:])
+			n[idx] = value
+			return n, true
+		}
+
+		idx, ok := arrayIndex(token, len(n), false)
+		if !ok {
+			return nil, false
+		}
+
+		updated, ok := setValue(n[idx], tokens[1:], value, replace)
+		if !ok {
+			return nil, false
+		}
+		n[idx] = updated
+		return n, true
+	}
+
+	return nil, false
+}
+
+func removeValue(node interface{}, tokens []string) (interface{}, bool) {
+	if len(tokens) == 0 {
+		return nil, false
+	}
+
+	token := tokens[0]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(tokens) == 1 {
+			if _, exist := n[token]; !exist {
+				return nil, false
+			}
+			delete(n, token)
+			return n, true
+		}
+
+		child, exist := n[token]
+		if !exist {
+			return nil, false
+		}
+
+		updated, ok := removeValue(child, tokens[1:])
+		if !ok {
+			return nil, false
+		}
+		n[token] = updated
+		return n, true
+
+	case []interface{}:
+		idx, ok := arrayIndex(token, len(n), false)
+		if !ok {
+			return nil, false
+		}
+
+		if len(tokens) == 1 {
+			return append(n[:idx], n[idx+1:]...), true
+		}
+
+		updated, ok := removeValue(n[idx], tokens[1:])
+		if !ok {
+			return nil, false
+		}
+		n[idx] = updated
+		return n, true
+	}
+
+	return nil, false
+}
+
+// deepCopy duplicates a decoded document, so copied values do not share containers
+func deepCopy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			c[k] = deepCopy(item)
+		}
+		return c
+
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, item := range v {
+			c[i] = deepCopy(item)
+		}
+		return c
+	}
+
+	return value
+}
+
+func jsonEqual(a interface{}, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, item := range av {
+			other, exist := bv[k]
+			if !exist || !jsonEqual(item, other) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aErr := av.Float64()
+		bf, bErr := bv.Float64()
+		return aErr == nil && bErr == nil && af == bf
+	}
+
+	return a == b
+}
+
+func (p *JsonPatch) applyOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	invalidPath := &PatchError{Op: op.Op, Path: op.Path, Err: ErrPatchInvalidPath}
+	var ok bool
+
+	switch op.Op {
+	case "add":
+		doc, ok = setValue(doc, op.path, deepCopy(op.value), false)
+
+	case "replace":
+		doc, ok = setValue(doc, op.path, deepCopy(op.value), true)
+
+	case "remove":
+		doc, ok = removeValue(doc, op.path)
+
+	case "copy", "move":
+		var value interface{}
+		value, ok = getValue(doc, op.from)
+		if !ok {
+			return nil, &PatchError{Op: op.Op, Path: op.From, Err: ErrPatchInvalidPath}
+		}
+
+		value = deepCopy(value)
+		if op.Op == "move" {
+			if doc, ok = removeValue(doc, op.from); !ok {
+				return nil, invalidPath
+			}
+		}
+
+		doc, ok = setValue(doc, op.path, value, false)
+
+	case "test":
+		var value interface{}
+		value, ok = getValue(doc, op.path)
+		if ok && !jsonEqual(value, op.value) {
+			return nil, &PatchError{Op: op.Op, Path: op.Path, Err: ErrPatchTestFailed}
+		}
+	}
+
+	if !ok {
+		return nil, invalidPath
+	}
+
+	return doc, nil
+}
+
+// ApplyJSON runs all the operations in order. The first failing operation stops the patch
+func (p *JsonPatch) ApplyJSON(doc []byte) ([]byte, error) {
+	value, err := decodeDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range p.Operations {
+		if value, err = p.applyOperation(value, op); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(value)
+}
+
+func (p *JsonPatch) Apply(target interface{}) error {
+	return applyToEntity(p, target)
+}
+
+func mergeValue(target interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return deepCopy(patch)
+	}
+
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = make(map[string]interface{})
+	}
+
+	for k, v := range patchMap {
+		if v == nil {
+			delete(targetMap, k)
+		} else {
+			targetMap[k] = mergeValue(targetMap[k], v)
+		}
+	}
+
+	return targetMap
+}
+
+// ApplyJSON merges the patch into doc
+func (p *MergePatch) ApplyJSON(doc []byte) ([]byte, error) {
+	value, err := decodeDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeValue(value, p.document))
+}
+
+func (p *MergePatch) Apply(target interface{}) error {
+	return applyToEntity(p, target)
+}
+
+// Has reports if the merge patch sets or removes the given top level field
+func (p *MergePatch) Has(field string) bool {
+	fields, ok := p.document.(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	_, exist := fields[field]
+	return exist
+}
+
+type patchHandler struct {
+	mergePatch bool
+}
+
+func (g patchHandler) getPatch(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	expected := "application/json-patch+json"
+	if g.mergePatch {
+		expected = "application/merge-patch+json"
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if mediaType != expected && mediaType != "application/json" {
+		abortWithError(ctx, http.StatusUnsupportedMediaType, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API needs a valid payload",
+				DevMsg: fmt.Sprintf("Expected Content-Type %s, got %s", expected, mediaType),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	body, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API needs a valid payload",
+				DevMsg: fmt.Sprintf("Failed to read patch payload. %v", err),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	var patch Patch
+	if g.mergePatch {
+		patch, err = ParseMergePatch(body)
+	} else {
+		patch, err = ParseJsonPatch(body)
+	}
+
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, api.Model{
+			Error: api.ErrorModel{
+				Code:   PatchErrorStatus(err),
+				Msg:    "API needs a valid patch payload",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	ctx.Set(api.InputModelKey, patch)
+	ctx.Next()
+}
@@ -0,0 +1,467 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode"
+)
+
+// PolicyInput contains the request attributes a policy expression is evaluated against
+type PolicyInput struct {
+	Tenant  string
+	User    string
+	Roles   []string
+	Scopes  []string
+	Params  map[string]string
+	Headers map[string]string
+}
+
+// Policy is a compiled authorization expression. The expression language supports:
+//
+//	tenant, user                  caller identity
+//	param["id"], header["X-Env"]  path parameters and request headers
+//	has_role("admin")             caller roles from the RoleProvider
+//	has_scope("items:write")      caller scopes from the authentication stage
+//	"text", ["a", "b"], true      literals
+//	==, !=, in, !, &&, ||, ( )    operators
+//
+// e.g. has_role("admin") || (tenant == param["tenant"] && has_scope("items:read"))
+type Policy struct {
+	source string
+	root   policyNode
+	calls  map[string]bool
+}
+
+// CompilePolicy parses a policy expression
+func CompilePolicy(expr string) (*Policy, error) {
+	p := &policyParser{tokens: tokenizePolicy(expr), calls: make(map[string]bool)}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid policy %q. %v", expr, err)
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("Invalid policy %q. Unexpected %q", expr, p.tokens[p.pos])
+	}
+
+	return &Policy{source: expr, root: root, calls: p.calls}, nil
+}
+
+// Allow evaluates the policy. Expressions not resolving to a boolean deny the access,
+// as well as the comparisons of tenant, user, params and headers missing from the request
+func (p *Policy) Allow(input PolicyInput) bool {
+	result, ok := p.root.eval(input).(bool)
+	return ok && result
+}
+
+// Uses reports if the policy calls the function name, e.g. has_role
+func (p *Policy) Uses(name string) bool {
+	return p.calls[name]
+}
+
+func (p *Policy) String() string {
+	return p.source
+}
+
+// PolicySet is a named collection of compiled policies
+type PolicySet struct {
+	policies map[string]*Policy
+}
+
+// NewPolicySet compiles the given name to expression map
+func NewPolicySet(policies map[string]string) (*PolicySet, error) {
+	set := &PolicySet{policies: make(map[string]*Policy)}
+
+	for name, expr := range policies {
+		policy, err := CompilePolicy(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Policy %s: %v", name, err)
+		}
+		set.policies[name] = policy
+	}
+
+	return set, nil
+}
+
+// LoadPolicies reads a JSON file containing an object mapping policy names to expressions
+func LoadPolicies(path string) (*PolicySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies map[string]string
+	if err := json.Unmarshal(data, &policies); err != nil {
+		return nil, fmt.Errorf("Invalid policy file %s. %v", path, err)
+	}
+
+	return NewPolicySet(policies)
+}
+
+// Get returns the policy registered with name
+func (s *PolicySet) Get(name string) (*Policy, bool) {
+	policy, exist := s.policies[name]
+	return policy, exist
+}
+
+type policyNode interface {
+	eval(input PolicyInput) interface{}
+}
+
+type literalNode struct{ value interface{} }
+
+type identNode struct{ name string }
+
+type indexNode struct {
+	source string
+	key    policyNode
+}
+
+type callNode struct {
+	name string
+	arg  policyNode
+}
+
+type unaryNode struct{ operand policyNode }
+
+type binaryNode struct {
+	op          string
+	left, right policyNode
+}
+
+// missingValue is the value of the attributes absent from the request. Comparisons
+// involving it evaluate to missingValue too, which is not a boolean and so denies the access
+type missingValue struct{}
+
+func isMissing(values ...interface{}) bool {
+	for _, value := range values {
+		if _, missing := value.(missingValue); missing {
+			return true
+		}
+	}
+	return false
+}
+
+// present turns the empty attributes into missingValue
+func present(value string, exist bool) interface{} {
+	if !exist || value == "" {
+		return missingValue{}
+	}
+	return value
+}
+
+func (n literalNode) eval(input PolicyInput) interface{} {
+	return n.value
+}
+
+func (n identNode) eval(input PolicyInput) interface{} {
+	if n.name == "tenant" {
+		return present(input.Tenant, true)
+	}
+	return present(input.User, true)
+}
+
+func (n indexNode) eval(input PolicyInput) interface{} {
+	key, ok := n.key.eval(input).(string)
+	if !ok {
+		return missingValue{}
+	}
+
+	if n.source == "param" {
+		value, exist := input.Params[key]
+		return present(value, exist)
+	}
+
+	for name, value := range input.Headers {
+		if strings.EqualFold(name, key) {
+			return present(value, true)
+		}
+	}
+	return missingValue{}
+}
+
+func (n callNode) eval(input PolicyInput) interface{} {
+	arg, _ := n.arg.eval(input).(string)
+
+	if n.name == "has_role" {
+		return contains(input.Roles, arg)
+	}
+	return contains(input.Scopes, arg)
+}
+
+func (n unaryNode) eval(input PolicyInput) interface{} {
+	value, ok := n.operand.eval(input).(bool)
+	return ok && !value
+}
+
+func (n binaryNode) eval(input PolicyInput) interface{} {
+	switch n.op {
+	case "&&":
+		left, _ := n.left.eval(input).(bool)
+		if !left {
+			return false
+		}
+		right, _ := n.right.eval(input).(bool)
+		return right
+
+	case "||":
+		if left, _ := n.left.eval(input).(bool); left {
+			return true
+		}
+		right, _ := n.right.eval(input).(bool)
+		return right
+
+	case "in":
+		left := n.left.eval(input)
+		if isMissing(left) {
+			return missingValue{}
+		}
+
+		value, _ := left.(string)
+		right, _ := n.right.eval(input).([]string)
+		return contains(right, value)
+	}
+
+	left := n.left.eval(input)
+	right := n.right.eval(input)
+	if isMissing(left, right) {
+		return missingValue{}
+	}
+
+	equal := fmt.Sprintf("%T:%v", left, left) == fmt.Sprintf("%T:%v", right, right)
+
+	if n.op == "==" {
+		return equal
+	}
+	return !equal
+}
+
+func tokenizePolicy(expr string) []string {
+	var tokens []string
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(runes) {
+				// unterminated strings are reported by the parser
+				tokens = append(tokens, string(runes[i:]))
+				return tokens
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+
+		case i+1 < len(runes) && (string(runes[i:i+2]) == "&&" || string(runes[i:i+2]) == "||" ||
+			string(runes[i:i+2]) == "==" || string(runes[i:i+2]) == "!="):
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+
+		default:
+			tokens = append(tokens, string(r))
+			i++
+		}
+	}
+
+	return tokens
+}
+
+type policyParser struct {
+	tokens []string
+	pos    int
+	calls  map[string]bool
+}
+
+func (p *policyParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *policyParser) expect(token string) error {
+	if p.peek() != token {
+		return fmt.Errorf("Expected %q, got %q", token, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *policyParser) parseOr() (policyNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "||", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *policyParser) parseAnd() (policyNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "&&", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *policyParser) parseNot() (policyNode, error) {
+	if p.peek() == "!" {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *policyParser) parseComparison() (policyNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if op := p.peek(); op == "==" || op == "!=" || op == "in" {
+		p.pos++
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{op: op, left: left, right: right}, nil
+	}
+
+	return left, nil
+}
+
+func (p *policyParser) parseString() (string, error) {
+	token := p.peek()
+	if len(token) < 2 || token[0] != '"' || token[len(token)-1] != '"' {
+		return "", fmt.Errorf("Expected string, got %q", token)
+	}
+	p.pos++
+
+	var value string
+	if err := json.Unmarshal([]byte(token), &value); err != nil {
+		return "", fmt.Errorf("Invalid string %s", token)
+	}
+	return value, nil
+}
+
+func (p *policyParser) parseOperand() (policyNode, error) {
+	token := p.peek()
+
+	switch {
+	case token == "(":
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return node, p.expect(")")
+
+	case token == "[":
+		p.pos++
+		var items []string
+		for p.peek() != "]" {
+			item, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+			if p.peek() == "," {
+				p.pos++
+			} else if p.peek() != "]" {
+				return nil, fmt.Errorf("Expected \",\" or \"]\", got %q", p.peek())
+			}
+		}
+		p.pos++
+		return literalNode{value: items}, nil
+
+	case strings.HasPrefix(token, "\""):
+		value, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalNode{value: value}, nil
+
+	case token == "true" || token == "false":
+		p.pos++
+		return literalNode{value: token == "true"}, nil
+
+	case token == "tenant" || token == "user":
+		p.pos++
+		return identNode{name: token}, nil
+
+	case token == "param" || token == "header":
+		p.pos++
+		if err := p.expect("["); err != nil {
+			return nil, err
+		}
+		key, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return indexNode{source: token, key: key}, p.expect("]")
+
+	case token == "has_role" || token == "has_scope":
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		p.calls[token] = true
+		return callNode{name: token, arg: arg}, p.expect(")")
+	}
+
+	if token == "" {
+		return nil, fmt.Errorf("Unexpected end of expression")
+	}
+	return nil, fmt.Errorf("Unexpected %q", token)
+}
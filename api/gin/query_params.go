@@ -24,6 +24,6 @@ func (g queryParamsHandler) getQueryParams(ctx *gin.Context) {
 		g.log.Warn(logCtx, "No query params found to be parsed. %v", err)
 	}
 
-	ctx.Set(api.InputModelKey, emptyModel)
+	ctx.Set(api.QueryParamsKey, emptyModel)
 	ctx.Next()
 }
@@ -0,0 +1,94 @@
+package gin
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+)
+
+// Kinds of the registered routes
+const (
+	RouteKindService = "service"
+	RouteKindJsonRpc = "jsonrpc"
+	RouteKindBatch   = "batch"
+	RouteKindAsync   = "async"
+)
+
+// Modes reported by RouteInfo for tenant and transaction stages
+const (
+	RouteModeNone      = "none"
+	RouteModeHeaders   = "headers"
+	RouteModeApiKey    = "apikey"
+	RouteModeManaged   = "managed"
+	RouteModeUnmanaged = "unmanaged"
+	RouteModeCustom    = "custom"
+)
+
+// RouteInfo describes the pipeline of a registered route
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Kind   string `json:"kind"`
+
+	// Tenant and transaction stages, one of the RouteMode values
+	Tenant string `json:"tenant"`
+	Tx     string `json:"tx"`
+
+	// Types of the bound models, RouteModeCustom for custom stages
+	Headers     string   `json:"headers,omitempty"`
+	InputModel  string   `json:"inputModel,omitempty"`
+	InputParams []string `json:"inputParams,omitempty"`
+	TypedParams string   `json:"typedParams,omitempty"`
+	QueryParams string   `json:"queryParams,omitempty"`
+
+	// Number of custom hooks run before and after the service
+	BeforeRun int `json:"beforeRun"`
+	AfterRun  int `json:"afterRun"`
+
+	// Feature flags gating the route
+	Features []string `json:"features,omitempty"`
+
+	// Optional gin stages of the pipeline, in execution order
+	Stages []string `json:"stages,omitempty"`
+}
+
+func typeName(model interface{}) string {
+	return reflect.TypeOf(model).String()
+}
+
+// routeRegistry collects the routes added to a gin Http
+type routeRegistry struct {
+	mu     sync.RWMutex
+	routes []RouteInfo
+}
+
+func (r *routeRegistry) add(info RouteInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = append(r.routes, info)
+}
+
+func (r *routeRegistry) list() []RouteInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	routes := make([]RouteInfo, len(r.routes))
+	copy(routes, r.routes)
+	return routes
+}
+
+func (g ginHttp) Routes() []RouteInfo {
+	return g.registry.list()
+}
+
+// RoutesService is a service answering with the routes of h. Register it on an admin
+// Http, or on h itself, to expose the route registry, e.g.
+//
+//	admin.AddRoute(http.MethodGet, "/routes", config, gin.RoutesService(public))
+func RoutesService(h Http) api.Service {
+	return func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return serviceOutput{status: api.ApiErrorNoError, data: h.Routes()}
+	}
+}
@@ -23,28 +23,18 @@ func (g resultHandler) handleResult(ctx *gin.Context) {
 	svcCtx := rCtx.(runtime.Context)
 
 	if svcRes.Status() != api.ApiErrorNoError {
-		httpCode := http.StatusInternalServerError
-
-		switch svcRes.Status() {
-		case api.ApiErrorAuthFailed:
-			httpCode = http.StatusForbidden
-		case api.ApiErrorEntityAlreadyExists:
-			httpCode = http.StatusConflict
-		case api.ApiErrorEntityDoesNotExists:
-			httpCode = http.StatusNotFound
-		case api.ApiErrorMissingRequiredItem:
-			httpCode = http.StatusBadRequest
-		case api.ApiErrorUnexpected:
-			httpCode = http.StatusInternalServerError
-		case api.ApiErrorUnknownItemRequested:
-			httpCode = http.StatusBadRequest
+		httpCode := httpStatus(svcRes.Status())
+
+		devMsg := ""
+		if svcRes.Err() != nil {
+			devMsg = svcRes.Err().Error()
 		}
 
-		ctx.AbortWithStatusJSON(httpCode, api.Model{
+		abortWithError(ctx, httpCode, api.Model{
 			Error: api.ErrorModel{
 				Code:   svcRes.Status(),
 				Msg:    svcRes.ErrMessage(),
-				DevMsg: svcRes.Err().Error(),
+				DevMsg: devMsg,
 				CorrId: svcCtx.Log().CorrID(),
 			},
 		})
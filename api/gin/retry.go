@@ -0,0 +1,211 @@
+package gin
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/abstracte/storage"
+	rruntime "github.com/hellcats88/rem/runtime"
+)
+
+// ErrRetryable marks the transient failures, like serialization failures and deadlocks.
+// Services and storages wrap it, e.g. fmt.Errorf("%w. %v", gin.ErrRetryable, err), to have
+// the routes with a RetryPolicy run again
+var ErrRetryable = errors.New("Retryable failure")
+
+// RetryPolicy re-runs the service of a managed transaction route in a fresh transaction when
+// it or the commit fail with a retryable error. Services must not depend on side effects
+// outside of the transaction, since they can run several times for the same request
+type RetryPolicy struct {
+	// Runs of the service, including the first one. Default: 3
+	MaxAttempts int
+
+	// Wait before the first retry, doubled at each further retry. Default: 50ms
+	Backoff time.Duration
+
+	// Upper bound of the wait between two attempts. Default: 2 seconds
+	MaxBackoff time.Duration
+
+	// Classifies the service and commit errors. Default: IsRetryable
+	Retryable func(error) bool
+}
+
+// IsRetryable reports if err wraps ErrRetryable or is a temporary error
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrRetryable) {
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
+
+type retryHandler struct {
+	policy RetryPolicy
+	tx     transactionHandler
+	log    logging.Logger
+}
+
+func newRetryHandler(policy RetryPolicy, tx transactionHandler, log logging.Logger) *retryHandler {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+
+	if policy.Backoff <= 0 {
+		policy.Backoff = 50 * time.Millisecond
+	}
+
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 2 * time.Second
+	}
+
+	if policy.Retryable == nil {
+		policy.Retryable = IsRetryable
+	}
+
+	return &retryHandler{policy: policy, tx: tx, log: log}
+}
+
+func (g retryHandler) retryable(attempt int, err error) bool {
+	return attempt < g.policy.MaxAttempts && err != nil && g.policy.Retryable(err)
+}
+
+// wait sleeps the backoff of attempt, with jitter. It returns false if the client went away
+func (g retryHandler) wait(ctx *gin.Context, attempt int) bool {
+	backoff := g.policy.Backoff << uint(attempt-1)
+	if backoff <= 0 || backoff > g.policy.MaxBackoff {
+		backoff = g.policy.MaxBackoff
+	}
+	backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Request.Context().Done():
+		return false
+	}
+}
+
+// runService replaces the service and commit stages of the managed transaction routes,
+// running the service and committing its transaction until an attempt succeeds
+func (g retryHandler) runService(service api.Service) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// ignore exist result because runtime context is mandatory
+		// and the user cannot remove it
+		iRCtx, _ := ctx.Get(api.RuntimeKey)
+		rCtx := iRCtx.(runtime.Context)
+
+		// the batch route commits or rolls back once all its sub-requests completed
+		if _, ok := batchTransaction(ctx); ok {
+			ctx.Set(api.ServiceResultKey, service(rCtx, ginServiceInput{ctx: ctx}))
+			return
+		}
+
+		logCtx := rCtx.Log()
+
+		for attempt := 1; ; attempt++ {
+			output := service(rCtx, ginServiceInput{ctx: ctx})
+
+			var failure error
+			if output.Status() != api.ApiErrorNoError {
+				if err := rCtx.Tx().Rollback(); err != nil {
+					abortWithError(ctx, http.StatusInternalServerError, api.Model{
+						Error: api.ErrorModel{
+							Code:   api.ApiErrorUnexpected,
+							Msg:    "Failed to rollback changes",
+							DevMsg: err.Error(),
+							CorrId: logCtx.CorrID(),
+						},
+					})
+					return
+				}
+
+				failure = output.Err()
+			} else if err := rCtx.Tx().Commit(); err != nil {
+				failure = err
+
+				if !g.retryable(attempt, err) {
+					g.log.Error(logCtx, "Failed to commit %s %s at attempt %d of %d. %v",
+						ctx.Request.Method, ctx.FullPath(), attempt, g.policy.MaxAttempts, err)
+
+					abortWithError(ctx, http.StatusInternalServerError, api.Model{
+						Error: api.ErrorModel{
+							Code:   api.ApiErrorUnexpected,
+							Msg:    "Failed to commit changes",
+							DevMsg: err.Error(),
+							CorrId: logCtx.CorrID(),
+						},
+					})
+					return
+				}
+			}
+
+			if !g.retryable(attempt, failure) {
+				if attempt > 1 {
+					g.log.Info(logCtx, "Completed %s %s at attempt %d of %d with status %d",
+						ctx.Request.Method, ctx.FullPath(), attempt, g.policy.MaxAttempts, output.Status())
+				}
+
+				ctx.Set(api.ServiceResultKey, output)
+				return
+			}
+
+			g.log.Warn(logCtx, "Attempt %d of %d of %s %s failed, retrying in a fresh transaction. %v",
+				attempt, g.policy.MaxAttempts, ctx.Request.Method, ctx.FullPath(), failure)
+
+			if !g.wait(ctx, attempt) {
+				g.log.Warn(logCtx, "Request %s %s canceled while waiting to retry", ctx.Request.Method, ctx.FullPath())
+				ctx.Set(api.ServiceResultKey, output)
+				return
+			}
+
+			// deadlines are released once all the attempts are over
+			tx, cancel, ok := g.open(ctx, logCtx)
+			defer cancel()
+
+			if !ok {
+				return
+			}
+
+			rCtx = rruntime.NewFromTx(rCtx, tx)
+			ctx.Set(api.TxKey, tx)
+			ctx.Set(api.RuntimeKey, rCtx)
+		}
+	}
+}
+
+// open opens the transaction of a retry, aborting the request on failure.
+// cancel releases the transaction deadline once the request is over
+func (g retryHandler) open(ctx *gin.Context, logCtx logging.Context) (tx storage.Transaction, cancel context.CancelFunc, ok bool) {
+	open, cancel := g.tx.opener(ctx, true)
+
+	tx, err := g.tx.open(ctx.Request.Context(), logCtx, open)
+	if rejected, isOpen := err.(breakerOpenError); isOpen {
+		g.tx.breaker.reject(ctx, logCtx, rejected.retryAfter)
+		return nil, cancel, false
+	}
+
+	if err != nil {
+		abortWithError(ctx, http.StatusInternalServerError, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Failed to open new managed transaction",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return nil, cancel, false
+	}
+
+	return tx, cancel, true
+}
@@ -3,13 +3,26 @@ package gin
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/env"
 	"github.com/hellcats88/abstracte/logging"
 	"github.com/hellcats88/abstracte/storage"
 	"github.com/hellcats88/abstracte/tenant"
+	remEnv "github.com/hellcats88/rem/env"
+	"github.com/hellcats88/rem/feature"
 	"github.com/hellcats88/rem/runtime"
 )
 
 type runtimeHandler struct {
+	features *feature.Store
+	env      env.Context
+}
+
+func newRuntimeHandler(options ServerOptions) runtimeHandler {
+	if options.Env == nil {
+		options.Env = remEnv.New("Global")
+	}
+
+	return runtimeHandler{features: options.Features, env: options.Env}
 }
 
 func (g runtimeHandler) createRuntimeContext(ctx *gin.Context) {
@@ -25,7 +38,7 @@ func (g runtimeHandler) createRuntimeContext(ctx *gin.Context) {
 	iTenantCtx, _ := ctx.Get(api.TenantKey)
 	tenantCtx := iTenantCtx.(tenant.Context)
 
-	rCtx := runtime.New(logCtx, txCtx, tenantCtx)
+	rCtx := runtime.NewWithFlags(logCtx, txCtx, tenantCtx, g.env, g.features)
 	ctx.Set(api.RuntimeKey, rCtx)
 	ctx.Next()
 }
@@ -0,0 +1,154 @@
+package gin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/env"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/rem/feature"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// ServerOptions hardens the HTTP server created by NewWithOptions
+type ServerOptions struct {
+	// Timeouts of the underlying http.Server. Zero means no timeout
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// Maximum size of the request headers. Default: http.DefaultMaxHeaderBytes
+	MaxHeaderBytes int
+
+	// Maximum size of the request body of every route, unless overridden by
+	// ConfigBuilder.MaxBodySize. Zero means no limit
+	MaxBodyBytes int64
+
+	// Networks (IP or CIDR) of the proxies allowed to set the client IP through
+	// X-Forwarded-For and X-Real-IP. Nil keeps the gin default of trusting every proxy
+	TrustedProxies []string
+
+	// Serves HTTP/2 without TLS (h2c) next to HTTP/1.1
+	H2C bool
+
+	// Translates the error messages of all the routes according to Accept-Language
+	Localization LocalizationConfig
+
+	// Feature flags exposed by the runtime context and evaluated by ConfigBuilder.RequireFeature
+	Features *feature.Store
+
+	// Environment of the runtime context, evaluated by the feature flags. Default: Global
+	Env env.Context
+
+	// Maintenance switch, shared by the servers handled together. Default: a new switch
+	Maintenance *MaintenanceSwitch
+}
+
+// NewWithOptions creates a gin Http configured with options. The gin mode is global to the
+// process, callers set it with gin.SetMode before creating the servers
+func NewWithOptions(log logging.Logger, options ServerOptions) (Http, error) {
+	engine := gin.New()
+	engine.Use(gin.Logger(), gin.Recovery())
+
+	if options.TrustedProxies != nil {
+		if err := engine.SetTrustedProxies(options.TrustedProxies); err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxies. %v", err)
+		}
+	}
+
+	if options.Maintenance == nil {
+		options.Maintenance = NewMaintenanceSwitch()
+	}
+
+	return ginHttp{
+		engine:      engine,
+		log:         log,
+		preflights:  make(map[string]*corsPreflight),
+		options:     options,
+		running:     &runningServers{},
+		registry:    &routeRegistry{},
+		maintenance: options.Maintenance,
+	}, nil
+}
+
+// server creates the http.Server serving the engine on addr
+func (g ginHttp) server(addr string) *http.Server {
+	var handler http.Handler = g.engine
+	if g.options.H2C {
+		handler = h2c.NewHandler(g.engine, &http2.Server{IdleTimeout: g.options.IdleTimeout})
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       g.options.ReadTimeout,
+		ReadHeaderTimeout: g.options.ReadHeaderTimeout,
+		WriteTimeout:      g.options.WriteTimeout,
+		IdleTimeout:       g.options.IdleTimeout,
+		MaxHeaderBytes:    g.options.MaxHeaderBytes,
+	}
+}
+
+type bodyLimitHandler struct {
+	limit int64
+	log   logging.Logger
+}
+
+func (g bodyLimitHandler) tooLarge(ctx *gin.Context, logCtx logging.Context) {
+	g.log.Warn(logCtx, "Rejected request body larger than %d bytes", g.limit)
+
+	abortWithError(ctx, http.StatusRequestEntityTooLarge, api.Model{
+		Error: api.ErrorModel{
+			Code:   ApiErrorPayloadTooLarge,
+			Msg:    "Payload too large",
+			DevMsg: fmt.Sprintf("Request body must not exceed %d bytes", g.limit),
+			CorrId: logCtx.CorrID(),
+		},
+	})
+}
+
+// limitBody reads the request body upfront, so that every later stage sees a body within the limit
+func (g bodyLimitHandler) limitBody(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	if ctx.Request.ContentLength > g.limit {
+		g.tooLarge(ctx, logCtx)
+		return
+	}
+
+	if ctx.Request.Body == nil || ctx.Request.Body == http.NoBody {
+		ctx.Next()
+		return
+	}
+
+	body, err := ioutil.ReadAll(&limitedReadCloser{reader: ctx.Request.Body, closer: ctx.Request.Body, limit: g.limit})
+	if errors.Is(err, ErrBodyTooLarge) {
+		g.tooLarge(ctx, logCtx)
+		return
+	}
+
+	if err != nil {
+		abortWithError(ctx, http.StatusBadRequest, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API needs a valid payload",
+				DevMsg: fmt.Sprintf("Failed to read payload. %v", err),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	ctx.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	ctx.Next()
+}
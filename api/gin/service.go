@@ -5,6 +5,18 @@ import (
 	"github.com/hellcats88/abstracte/api"
 )
 
+// TypedParamsKey stores the typed path parameters model in the gin context
+const TypedParamsKey = "_rem_gin_typedparams_key"
+
+// ServiceInput extends api.ServiceInput with the inputs available only on gin routes.
+// Services receive it as api.ServiceInput and can type assert it
+type ServiceInput interface {
+	api.ServiceInput
+
+	// TypedParams returns a pointer to the model declared with ConfigBuilder.TypedParams
+	TypedParams() interface{}
+}
+
 type ginServiceInput struct {
 	ctx *gin.Context
 }
@@ -51,3 +63,12 @@ func (g ginServiceInput) Headers() interface{} {
 
 	return model
 }
+
+func (g ginServiceInput) TypedParams() interface{} {
+	model, ok := g.ctx.Get(TypedParamsKey)
+	if !ok {
+		panic("Missing required Typed Params. Is pipeline correct?")
+	}
+
+	return model
+}
@@ -27,7 +27,7 @@ func (g tenantHandler) createTenantFromHeaders(ctx *gin.Context) {
 	if tCtx.ID() == "" || tCtx.UserID() == "" {
 		g.log.Error(logCtx, "Rejected request caused by missing tenant informations")
 
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, api.Model{
+		abortWithError(ctx, http.StatusUnauthorized, api.Model{
 			Error: api.ErrorModel{
 				Code:   api.ApiErrorAuthFailed,
 				Msg:    "Failed to get user information",
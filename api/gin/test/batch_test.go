@@ -0,0 +1,196 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+type batchItemModel struct {
+	Name string `json:"name"`
+}
+
+func newBatchHarness(t *testing.T) (*apitest.Harness, *apitest.Storage) {
+	log := apitest.NewLogger()
+	db := apitest.NewStorage()
+	g := rgin.New(log)
+	h := apitest.New(g)
+
+	config := rgin.NewConfigBuilderWithStorage(log, db).
+		Tenant(api.ConfigTenantFromHeaders).
+		Tx(api.ConfigTxManaged).
+		InputModel(batchItemModel{}).
+		Build()
+
+	err := h.AddRoute(http.MethodPost, "/items", config, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		item := input.Model().(*batchItemModel)
+		if item.Name == "taken" {
+			return apitest.Fail(rgin.ApiErrorConflict, "Item already exists", errors.New("Duplicate name"))
+		}
+		return apitest.Ok(item)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.AddBatchRoute("/batch", rgin.BatchConfig{Storage: db, MaxItems: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	return h, db
+}
+
+func createItem(name string) rgin.BatchItem {
+	return rgin.BatchItem{Method: http.MethodPost, Path: "/items", Body: []byte(`{"name":"` + name + `"}`)}
+}
+
+func TestBatchCommitsSharedTransaction(t *testing.T) {
+	h, db := newBatchHarness(t)
+
+	var results []rgin.BatchItemResult
+	h.Post("/batch").Tenant("t1", "u1").JSON([]rgin.BatchItem{createItem("a"), createItem("b")}).Do().
+		AssertStatus(t, http.StatusOK).
+		AssertData(t, &results)
+
+	if len(results) != 2 || results[0].Status != http.StatusOK || results[1].Status != http.StatusOK {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	// the items run in the transaction of the batch instead of opening their own
+	if n := len(db.Transactions()); n != 1 {
+		t.Errorf("Expected 1 transaction, %d opened", n)
+	}
+	db.AssertCommitted(t)
+}
+
+func TestBatchRollsBackOnFailure(t *testing.T) {
+	h, db := newBatchHarness(t)
+
+	var results []rgin.BatchItemResult
+	h.Post("/batch").Tenant("t1", "u1").JSON([]rgin.BatchItem{createItem("a"), createItem("taken"), createItem("b")}).Do().
+		AssertStatus(t, http.StatusConflict).
+		AssertErrorCode(t, rgin.ApiErrorConflict).
+		AssertData(t, &results)
+
+	expected := []int{http.StatusOK, http.StatusConflict, http.StatusFailedDependency}
+	for i, status := range expected {
+		if i >= len(results) || results[i].Status != status {
+			t.Fatalf("Expected item statuses %v, got %+v", expected, results)
+		}
+	}
+
+	if n := len(db.Transactions()); n != 1 {
+		t.Errorf("Expected 1 transaction, %d opened", n)
+	}
+	db.AssertRolledBack(t)
+}
+
+func TestBatchRollsBackUnknownRoutes(t *testing.T) {
+	h, db := newBatchHarness(t)
+
+	h.Post("/batch").Tenant("t1", "u1").JSON([]rgin.BatchItem{createItem("a"), {Method: http.MethodGet, Path: "/missing"}}).Do().
+		AssertStatus(t, http.StatusNotFound)
+
+	db.AssertRolledBack(t)
+}
+
+func TestBatchCommitFailure(t *testing.T) {
+	h, db := newBatchHarness(t)
+	db.FailCommit(errors.New("Connection lost"))
+
+	h.Post("/batch").Tenant("t1", "u1").JSON([]rgin.BatchItem{createItem("a")}).Do().
+		AssertStatus(t, http.StatusInternalServerError).
+		AssertErrorCode(t, api.ApiErrorUnexpected)
+}
+
+func TestBatchRejectsInvalidBatches(t *testing.T) {
+	tests := map[string][]rgin.BatchItem{
+		"empty":     {},
+		"too large": {createItem("a"), createItem("b"), createItem("c"), createItem("d")},
+		"nested":    {createItem("a"), {Method: http.MethodPost, Path: "/batch", Body: []byte(`[]`)}},
+		"nested uncleaned path": {
+			{Method: http.MethodPost, Path: "/items/../BATCH/?x=1", Body: []byte(`[]`)},
+		},
+	}
+
+	for name, items := range tests {
+		t.Run(name, func(t *testing.T) {
+			h, db := newBatchHarness(t)
+
+			h.Post("/batch").Tenant("t1", "u1").JSON(items).Do().AssertStatus(t, http.StatusBadRequest)
+			db.AssertNoTransaction(t)
+		})
+	}
+}
+
+func TestBatchRejectsRoutesOutsideItsTransaction(t *testing.T) {
+	h, db := newBatchHarness(t)
+	log := apitest.NewLogger()
+
+	runner := rgin.NewJobRunner(log, rgin.JobConfig{Storage: db})
+	defer runner.Close()
+
+	called := 0
+	service := func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		called++
+		ctx.Tx().Commit()
+		return apitest.Ok(nil)
+	}
+
+	configs := map[string]api.Config{
+		"/unmanaged": rgin.NewConfigBuilderWithStorage(log, db).Tenant(api.ConfigTenantFromHeaders).Tx(api.ConfigTxUnmanaged).Build(),
+		"/async":     rgin.NewConfigBuilderWithStorage(log, db).Async(runner).Tenant(api.ConfigTenantFromHeaders).Build(),
+		"/custom": rgin.NewConfigBuilderWithStorage(log, db).Tenant(api.ConfigTenantFromHeaders).
+			CustomTx(api.C{Handler: gin.HandlerFunc(func(ctx *gin.Context) {
+				tx, _ := db.UnmanagedTx()
+				ctx.Set(api.TxKey, tx)
+			})}).Build(),
+	}
+
+	for path, config := range configs {
+		if err := h.AddRoute(http.MethodPost, path, config, service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for path := range configs {
+		t.Run(path, func(t *testing.T) {
+			db.Reset()
+
+			// the second item fails, the first one must not have committed anything on its own
+			var results []rgin.BatchItemResult
+			h.Post("/batch").Tenant("t1", "u1").JSON([]rgin.BatchItem{{Method: http.MethodPost, Path: path}, createItem("taken")}).Do().
+				AssertStatus(t, http.StatusBadRequest).
+				AssertData(t, &results)
+
+			if len(results) != 2 || results[0].Status != http.StatusBadRequest || results[1].Status != http.StatusFailedDependency {
+				t.Errorf("Unexpected results %+v", results)
+			}
+
+			for _, tx := range db.Transactions() {
+				if tx.Outcome() == apitest.OutcomeCommitted {
+					t.Error("A transaction committed in a rolled back batch")
+				}
+			}
+
+			if n := len(db.Transactions()); n != 1 {
+				t.Errorf("Expected only the batch transaction, %d opened", n)
+			}
+			db.AssertRolledBack(t)
+		})
+	}
+
+	if called != 0 {
+		t.Errorf("Service called %d times inside a batch", called)
+	}
+
+	// outside of a batch the routes keep working
+	h.Post("/unmanaged").Tenant("t1", "u1").Do().AssertStatus(t, http.StatusOK)
+	h.Post("/async").Tenant("t1", "u1").Do().AssertStatus(t, http.StatusAccepted)
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+func newCacheHarness(t *testing.T, shared bool) (*apitest.Harness, *rgin.ResponseCache, *int) {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+	cache := rgin.NewResponseCache(nil)
+
+	calls := 0
+	config := rgin.NewConfigBuilder(log).
+		Cache(rgin.CacheConfig{Cache: cache, Shared: shared}).
+		Tenant(api.ConfigTenantFromHeaders).
+		Build()
+
+	err := h.AddRoute(http.MethodGet, "/items/:id", config, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		calls++
+		return apitest.Ok(ctx.Tenant().UserID())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h, cache, &calls
+}
+
+func getCached(t *testing.T, h *apitest.Harness, userID string, expectedUser string, expectedCache string) {
+	t.Helper()
+
+	var user string
+	res := h.Get("/items/1").Tenant("t1", userID).Do().AssertStatus(t, http.StatusOK).AssertData(t, &user)
+
+	if user != expectedUser || res.Header().Get("X-Cache") != expectedCache {
+		t.Errorf("User %s: expected %s from %s, got %s from %s", userID, expectedUser, expectedCache, user, res.Header().Get("X-Cache"))
+	}
+}
+
+func TestCachePerUser(t *testing.T) {
+	h, cache, calls := newCacheHarness(t, false)
+
+	getCached(t, h, "u1", "u1", "MISS")
+	getCached(t, h, "u1", "u1", "HIT")
+
+	// another user of the same tenant never sees the response of u1
+	getCached(t, h, "u2", "u2", "MISS")
+	getCached(t, h, "u2", "u2", "HIT")
+
+	if *calls != 2 {
+		t.Errorf("Expected 2 service calls, got %d", *calls)
+	}
+
+	// invalidating the route drops the entries of all the users
+	cache.Invalidate("t1", "/items/:id", "1")
+	getCached(t, h, "u1", "u1", "MISS")
+	getCached(t, h, "u2", "u2", "MISS")
+}
+
+func TestCacheSharedAcrossUsers(t *testing.T) {
+	h, _, calls := newCacheHarness(t, true)
+
+	getCached(t, h, "u1", "u1", "MISS")
+	getCached(t, h, "u2", "u1", "HIT")
+
+	if *calls != 1 {
+		t.Errorf("Expected 1 service call, got %d", *calls)
+	}
+}
+
+func TestCacheRequiresSharedWithoutTenant(t *testing.T) {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+	cache := rgin.NewResponseCache(nil)
+
+	service := func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok(nil)
+	}
+
+	config := rgin.NewConfigBuilder(log).Cache(rgin.CacheConfig{Cache: cache}).Tenant(api.ConfigTenantNo).Build()
+	if err := h.AddRoute(http.MethodGet, "/private", config, service); err == nil {
+		t.Error("AddRoute accepted a cached route without tenant sharing its responses implicitly")
+	}
+
+	config = rgin.NewConfigBuilder(log).Cache(rgin.CacheConfig{Cache: cache, Shared: true}).Tenant(api.ConfigTenantNo).Build()
+	if err := h.AddRoute(http.MethodGet, "/public", config, service); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+type compressedModel struct {
+	Name string `json:"name"`
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompressedRequestBody(t *testing.T) {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+
+	called := 0
+	config := rgin.NewConfigBuilder(log).
+		Compression(rgin.CompressionConfig{MaxDecompressedSize: 64 << 10}).
+		InputModel(compressedModel{}).
+		Build()
+
+	err := h.AddRoute(http.MethodPost, "/items", config, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		called++
+		return apitest.Ok(input.Model())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var item compressedModel
+	h.Post("/items").Body("application/json", gzipped(t, []byte(`{"name":"a"}`))).Header("Content-Encoding", "gzip").Do().
+		AssertStatus(t, http.StatusOK).
+		AssertData(t, &item)
+	if item.Name != "a" {
+		t.Errorf("Unexpected model %+v", item)
+	}
+
+	// about 1KB on the wire, 1MB once decompressed
+	bomb := gzipped(t, append(append([]byte(`{"name":"`), bytes.Repeat([]byte("a"), 1<<20)...), `"}`...))
+	h.Post("/items").Body("application/json", bomb).Header("Content-Encoding", "gzip").Do().
+		AssertStatus(t, http.StatusRequestEntityTooLarge).
+		AssertErrorCode(t, rgin.ApiErrorPayloadTooLarge)
+
+	h.Post("/items").Body("application/json", []byte(`{"name":"a"}`)).Header("Content-Encoding", "gzip").Do().
+		AssertStatus(t, http.StatusBadRequest)
+
+	if called != 1 {
+		t.Errorf("Expected 1 service call, got %d", called)
+	}
+}
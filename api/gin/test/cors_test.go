@@ -0,0 +1,83 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+func newCorsHarness(t *testing.T, config rgin.CorsConfig) *apitest.Harness {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+
+	err := h.AddRoute(http.MethodGet, "/items", rgin.NewConfigBuilder(log).Cors(config).Build(), func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok(nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+func TestCorsRejectsWildcardWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Cors accepted credentials for any origin")
+		}
+	}()
+
+	rgin.NewConfigBuilder(apitest.NewLogger()).Cors(rgin.CorsConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+}
+
+func TestCorsWildcardOrigin(t *testing.T) {
+	h := newCorsHarness(t, rgin.CorsConfig{AllowedOrigins: []string{"*"}})
+
+	res := h.Get("/items").Header("Origin", "https://any.example").Do().AssertStatus(t, http.StatusOK)
+	if origin := res.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("Expected the literal wildcard, got %q", origin)
+	}
+
+	res = h.Request(http.MethodOptions, "/items").
+		Header("Origin", "https://any.example").
+		Header("Access-Control-Request-Method", http.MethodGet).
+		Do().AssertStatus(t, http.StatusNoContent)
+	if origin := res.Header().Get("Access-Control-Allow-Origin"); origin != "*" {
+		t.Errorf("Expected the literal wildcard in the preflight, got %q", origin)
+	}
+}
+
+func TestCorsCredentials(t *testing.T) {
+	h := newCorsHarness(t, rgin.CorsConfig{AllowedOrigins: []string{"https://app.example"}, AllowCredentials: true})
+
+	tests := []struct {
+		origin      string
+		allowed     string
+		credentials string
+	}{
+		{"https://app.example", "https://app.example", "true"},
+		{"https://evil.example", "", ""},
+		{"", "", ""},
+	}
+
+	for _, test := range tests {
+		req := h.Get("/items")
+		if test.origin != "" {
+			req.Header("Origin", test.origin)
+		}
+
+		header := req.Do().AssertStatus(t, http.StatusOK).Header()
+		if header.Get("Access-Control-Allow-Origin") != test.allowed || header.Get("Access-Control-Allow-Credentials") != test.credentials {
+			t.Errorf("Origin %q: unexpected CORS headers %v", test.origin, header)
+		}
+
+		// the response depends on the origin even when it is not granted
+		if header.Get("Vary") != "Origin" {
+			t.Errorf("Origin %q: expected Vary: Origin, got %q", test.origin, header.Get("Vary"))
+		}
+	}
+}
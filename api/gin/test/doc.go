@@ -0,0 +1,4 @@
+// Package test holds the behaviour tests of the gin implementation, driven
+// through the apitest harness. It lives in its own module so the published
+// gin module does not depend on apitest
+package test
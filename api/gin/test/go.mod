@@ -0,0 +1,9 @@
+module github.com/hellcats88/rem/api/gin/test
+
+go 1.15
+
+require (
+	github.com/gin-gonic/gin v1.7.7
+	github.com/google/uuid v1.2.0
+	github.com/hellcats88/abstracte v0.0.0-20210531083423-6e6de49b09ed
+)
@@ -0,0 +1,221 @@
+package test
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/abstracte/security"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+// testModule is a SecureModule holding its keys in memory
+type testModule struct {
+	signers map[string]crypto.Signer
+	blocks  map[string][]byte
+}
+
+func newTestModule(t *testing.T) *testModule {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testModule{
+		signers: map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey},
+		blocks:  map[string][]byte{"svc": bytes.Repeat([]byte{7}, 32), "other": bytes.Repeat([]byte{9}, 32)},
+	}
+}
+
+func (m *testModule) GenerateRSAKeyPair(ctx runtime.Context, req security.GenerateRSAKeyPairReq) (crypto.PublicKey, error) {
+	return nil, errors.New("Not supported")
+}
+
+func (m *testModule) GenerateECDSAKeyPair(ctx runtime.Context, req security.GenerateECDSAKeyPairReq) (crypto.PublicKey, error) {
+	return nil, errors.New("Not supported")
+}
+
+func (m *testModule) Signer(ctx runtime.Context, alias string) (crypto.Signer, error) {
+	signer, exist := m.signers[alias]
+	if !exist {
+		return nil, errors.New("Unknown key " + alias)
+	}
+	return signer, nil
+}
+
+func (m *testModule) Block(ctx runtime.Context, alias string) (cipher.Block, error) {
+	key, exist := m.blocks[alias]
+	if !exist {
+		return nil, errors.New("Unknown key " + alias)
+	}
+	return aes.NewCipher(key)
+}
+
+func (m *testModule) GenerateAESKey(ctx runtime.Context, req security.GenerateAESKeyReq) (cipher.Block, error) {
+	return nil, errors.New("Not supported")
+}
+
+func (m *testModule) Capabilities() security.CapabilitiesResp {
+	return security.CapabilitiesResp{}
+}
+
+// captureTransport records the requests instead of sending them
+type captureTransport struct {
+	req *http.Request
+}
+
+func (c *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.req = req
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(nil)), Request: req}, nil
+}
+
+// signedRequest signs a request with the alias key of module, returning the signed headers
+func signedRequest(t *testing.T, module security.SecureModule, alias string, target string, body string, tenantID string) http.Header {
+	capture := &captureTransport{}
+	client := &http.Client{Transport: rgin.NewSigningTransport(capture, module, alias)}
+
+	req, _ := http.NewRequest(http.MethodPost, "http://localhost"+target, bytes.NewBufferString(body))
+	req.Header.Set("X-Tenant-ID", tenantID)
+	req.Header.Set("X-Tenant-UserID", "u1")
+
+	if _, err := client.Do(req); err != nil {
+		t.Fatal(err)
+	}
+
+	return capture.req.Header
+}
+
+func replay(h *apitest.Harness, target string, headers http.Header, body string) *apitest.Response {
+	req := h.Post(target).Body("application/json", []byte(body))
+	for name := range headers {
+		req.Header(name, headers.Get(name))
+	}
+	return req.Do()
+}
+
+func newSignedHarness(t *testing.T, module security.SecureModule) *apitest.Harness {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+
+	config := rgin.NewConfigBuilder(log).
+		SignedRequests(rgin.HmacConfig{Module: module, KeyAliases: []string{"svc"}}).
+		Tenant(api.ConfigTenantFromHeaders).
+		Build()
+
+	err := h.AddRoute(http.MethodPost, "/items", config, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok(ctx.Tenant().ID())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+func TestSignedRequestAccepted(t *testing.T) {
+	module := newTestModule(t)
+	h := newSignedHarness(t, module)
+
+	headers := signedRequest(t, module, "svc", "/items?page=1", `{"name":"a"}`, "t1")
+	var tenantID string
+	replay(h, "/items?page=1", headers, `{"name":"a"}`).AssertStatus(t, http.StatusOK).AssertData(t, &tenantID)
+
+	if tenantID != "t1" {
+		t.Errorf("Service ran for tenant %q, expected t1", tenantID)
+	}
+}
+
+func TestSignedRequestRejectsReplays(t *testing.T) {
+	module := newTestModule(t)
+	h := newSignedHarness(t, module)
+
+	headers := signedRequest(t, module, "svc", "/items", `{}`, "t1")
+	replay(h, "/items", headers, `{}`).AssertStatus(t, http.StatusOK)
+	replay(h, "/items", headers, `{}`).AssertStatus(t, http.StatusUnauthorized).AssertErrorCode(t, api.ApiErrorAuthFailed)
+}
+
+func TestSignedRequestRejectsTampering(t *testing.T) {
+	module := newTestModule(t)
+
+	tests := []struct {
+		name   string
+		tamper func(headers http.Header) (target string, body string)
+	}{
+		{"body", func(headers http.Header) (string, string) {
+			return "/items", `{"name":"b"}`
+		}},
+		{"query", func(headers http.Header) (string, string) {
+			return "/items?page=2", `{"name":"a"}`
+		}},
+		{"tenant", func(headers http.Header) (string, string) {
+			headers.Set("X-Tenant-ID", "t2")
+			return "/items", `{"name":"a"}`
+		}},
+		{"unaccepted key", func(headers http.Header) (string, string) {
+			headers.Set(rgin.HeaderSignatureKey, "other")
+			return "/items", `{"name":"a"}`
+		}},
+		{"expired timestamp", func(headers http.Header) (string, string) {
+			headers.Set(rgin.HeaderTimestamp, strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10))
+			return "/items", `{"name":"a"}`
+		}},
+		{"missing nonce", func(headers http.Header) (string, string) {
+			headers.Del(rgin.HeaderNonce)
+			return "/items", `{"name":"a"}`
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := newSignedHarness(t, module)
+			headers := signedRequest(t, module, "svc", "/items", `{"name":"a"}`, "t1")
+
+			target, body := test.tamper(headers)
+			replay(h, target, headers, body).AssertStatus(t, http.StatusUnauthorized).AssertErrorCode(t, api.ApiErrorAuthFailed)
+		})
+	}
+}
+
+func TestSignedRequestWithOtherKeyRejected(t *testing.T) {
+	module := newTestModule(t)
+	h := newSignedHarness(t, module)
+
+	headers := signedRequest(t, module, "other", "/items", `{}`, "t1")
+	headers.Set(rgin.HeaderSignatureKey, "svc")
+	replay(h, "/items", headers, `{}`).AssertStatus(t, http.StatusUnauthorized)
+}
+
+func TestMemoryNonceCache(t *testing.T) {
+	cache := rgin.NewMemoryNonceCache()
+
+	if cache.Seen("n1", time.Now().Add(time.Minute)) {
+		t.Error("First use of n1 reported as seen")
+	}
+
+	if !cache.Seen("n1", time.Now().Add(time.Minute)) {
+		t.Error("Second use of n1 not reported as seen")
+	}
+
+	if cache.Seen("n2", time.Now().Add(-time.Second)) || cache.Seen("n2", time.Now().Add(time.Minute)) {
+		t.Error("Expired nonce n2 reported as seen")
+	}
+}
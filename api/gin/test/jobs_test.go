@@ -0,0 +1,93 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+	rstorage "github.com/hellcats88/rem/storage"
+)
+
+// runJob submits a job to the route and waits for its completion
+func runJob(t *testing.T, h *apitest.Harness, runner *rgin.JobRunner) rgin.Job {
+	t.Helper()
+
+	var job rgin.Job
+	h.Post("/reports").Tenant("t1", "u1").Do().AssertStatus(t, http.StatusAccepted).AssertData(t, &job)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		current, err := runner.Job("t1", job.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if current.Status == rgin.JobSucceeded || current.Status == rgin.JobFailed {
+			return current
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("Job %s did not complete", job.ID)
+	return job
+}
+
+func TestJobTransactionOfTheRoute(t *testing.T) {
+	log := apitest.NewLogger()
+	db := apitest.NewStorage()
+	h := apitest.New(rgin.New(log))
+
+	runner := rgin.NewJobRunner(log, rgin.JobConfig{Storage: db, Workers: 1})
+	defer runner.Close()
+
+	breaker := rgin.NewCircuitBreaker(log, rgin.CircuitBreakerConfig{Threshold: 1, Cooldown: time.Hour})
+	options := rstorage.TxOptions{Isolation: rstorage.IsolationSerializable, Timeout: time.Minute}
+
+	config := rgin.NewConfigBuilderWithStorage(log, db).
+		Async(runner).
+		CircuitBreaker(breaker).
+		TxOptions(options).
+		Tenant(api.ConfigTenantFromHeaders).
+		Build()
+
+	err := h.AddRoute(http.MethodPost, "/reports", config, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok("done")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job := runJob(t, h, runner); job.Status != rgin.JobSucceeded {
+		t.Fatalf("Expected a succeeded job, got %+v", job)
+	}
+
+	tx := db.Last()
+	if tx.Options() != options || !tx.Managed() || tx.Outcome() != apitest.OutcomeCommitted {
+		t.Errorf("Job transaction opened with %+v, managed %v, %s", tx.Options(), tx.Managed(), tx.Outcome())
+	}
+
+	if _, ok := tx.Deadline(); !ok {
+		t.Error("Job transaction opened without the route timeout")
+	}
+
+	// a failure opening the transaction trips the breaker of the route
+	db.FailTx(errors.New("Connection refused"))
+	if job := runJob(t, h, runner); job.Status != rgin.JobFailed || job.Error.Code != api.ApiErrorUnexpected {
+		t.Fatalf("Expected a failed job, got %+v", job)
+	}
+
+	if breaker.State() != rgin.BreakerOpen {
+		t.Fatalf("Expected an open breaker, got %s", breaker.State())
+	}
+
+	db.Reset()
+	if job := runJob(t, h, runner); job.Status != rgin.JobFailed || job.Error.Code != rgin.ApiErrorUnavailable {
+		t.Errorf("Expected a job rejected by the breaker, got %+v", job)
+	}
+	db.AssertNoTransaction(t)
+}
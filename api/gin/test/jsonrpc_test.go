@@ -0,0 +1,235 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+type rpcResponse struct {
+	JsonRpc string             `json:"jsonrpc"`
+	Result  json.RawMessage    `json:"result"`
+	Error   *rgin.JsonRpcError `json:"error"`
+	ID      json.RawMessage    `json:"id"`
+}
+
+type sumParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+// newJsonRpc serves a dispatcher with a few methods on /rpc. Calls of notify are counted in notified
+func newJsonRpc(t *testing.T, log *apitest.Logger, notified *int) *apitest.Harness {
+	t.Helper()
+
+	g := rgin.New(log)
+	rpc := rgin.NewJsonRpc(log)
+
+	rpc.Register("sum", rgin.JsonRpcConfig{Params: sumParams{}}, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		params := input.Model().(*sumParams)
+		return apitest.Ok(params.A + params.B)
+	})
+	rpc.Register("notify", rgin.JsonRpcConfig{}, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		*notified++
+		return apitest.Ok(nil)
+	})
+	rpc.Register("whoami", rgin.JsonRpcConfig{Tenant: api.ConfigTenantFromHeaders}, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok(ctx.Tenant().UserID())
+	})
+	rpc.Register("panic", rgin.JsonRpcConfig{}, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		panic("boom")
+	})
+
+	if err := g.AddJsonRpc("/rpc", rpc); err != nil {
+		t.Fatal(err)
+	}
+	return apitest.New(g)
+}
+
+func rpcCall(t *testing.T, h *apitest.Harness, body string) rpcResponse {
+	t.Helper()
+
+	res := h.Post("/rpc").Body("application/json", []byte(body)).Do().AssertStatus(t, http.StatusOK)
+
+	var out rpcResponse
+	if err := json.Unmarshal(res.Recorder.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func assertRpcError(t *testing.T, res rpcResponse, code int) {
+	t.Helper()
+
+	if res.Error == nil {
+		t.Fatalf("Expected error %d, got result %s", code, res.Result)
+	}
+
+	if res.Error.Code != code {
+		t.Errorf("Expected error %d, got %+v", code, res.Error)
+	}
+}
+
+func TestJsonRpcCall(t *testing.T) {
+	log := apitest.NewLogger()
+	notified := 0
+	h := newJsonRpc(t, log, &notified)
+
+	res := rpcCall(t, h, `{"jsonrpc":"2.0","method":"sum","params":{"a":1,"b":2},"id":1}`)
+	if res.Error != nil || string(res.Result) != "3" || string(res.ID) != "1" || res.JsonRpc != "2.0" {
+		t.Errorf("Unexpected response %+v", res)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"parse error", `{"jsonrpc":"2.0",`, rgin.JsonRpcParseError},
+		{"missing version", `{"method":"sum","id":1}`, rgin.JsonRpcInvalidRequest},
+		{"missing method", `{"jsonrpc":"2.0","id":1}`, rgin.JsonRpcInvalidRequest},
+		{"empty batch", `[]`, rgin.JsonRpcInvalidRequest},
+		{"unknown method", `{"jsonrpc":"2.0","method":"div","id":1}`, rgin.JsonRpcMethodNotFound},
+		{"missing params", `{"jsonrpc":"2.0","method":"sum","id":1}`, rgin.JsonRpcInvalidParams},
+		{"invalid params", `{"jsonrpc":"2.0","method":"sum","params":{"a":"x"},"id":1}`, rgin.JsonRpcInvalidParams},
+		{"panic", `{"jsonrpc":"2.0","method":"panic","id":1}`, rgin.JsonRpcInternalError},
+		{"missing tenant", `{"jsonrpc":"2.0","method":"whoami","id":1}`, rgin.JsonRpcServerError - int(api.ApiErrorAuthFailed)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertRpcError(t, rpcCall(t, h, test.body), test.code)
+		})
+	}
+
+	out := h.Post("/rpc").Body("application/json", []byte(`{"jsonrpc":"2.0","method":"whoami","id":"a"}`)).Tenant("t1", "u1").Do().
+		AssertStatus(t, http.StatusOK)
+
+	var user string
+	if err := json.Unmarshal(out.Recorder.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(res.Result, &user); err != nil || user != "u1" || string(res.ID) != `"a"` {
+		t.Errorf("Unexpected response %+v. %v", res, err)
+	}
+
+	log.AssertLogged(t, logging.Error, "JSON-RPC method panic failed")
+}
+
+func TestJsonRpcNotification(t *testing.T) {
+	log := apitest.NewLogger()
+	notified := 0
+	h := newJsonRpc(t, log, &notified)
+
+	h.Post("/rpc").Body("application/json", []byte(`{"jsonrpc":"2.0","method":"notify"}`)).Do().
+		AssertStatus(t, http.StatusNoContent)
+
+	// a failed notification is not answered either
+	h.Post("/rpc").Body("application/json", []byte(`{"jsonrpc":"2.0","method":"panic"}`)).Do().
+		AssertStatus(t, http.StatusNoContent)
+
+	h.Post("/rpc").Body("application/json", []byte(`[{"jsonrpc":"2.0","method":"notify"},{"jsonrpc":"2.0","method":"unknown"}]`)).Do().
+		AssertStatus(t, http.StatusNoContent)
+
+	if notified != 2 {
+		t.Errorf("Expected 2 notifications, got %d", notified)
+	}
+}
+
+func TestJsonRpcBatch(t *testing.T) {
+	log := apitest.NewLogger()
+	notified := 0
+	h := newJsonRpc(t, log, &notified)
+
+	body := `[
+		{"jsonrpc":"2.0","method":"sum","params":{"a":1,"b":1},"id":1},
+		{"jsonrpc":"2.0","method":"panic","id":2},
+		{"jsonrpc":"2.0","method":"notify"},
+		{"jsonrpc":"2.0","method":"sum","params":{"a":2,"b":2},"id":3},
+		1
+	]`
+
+	res := h.Post("/rpc").Body("application/json", []byte(body)).Do().AssertStatus(t, http.StatusOK)
+
+	var batch []rpcResponse
+	if err := json.Unmarshal(res.Recorder.Body.Bytes(), &batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batch) != 4 {
+		t.Fatalf("Expected 4 responses, got %d", len(batch))
+	}
+
+	// a panicking method fails only its own call
+	if string(batch[0].Result) != "2" || string(batch[2].Result) != "4" {
+		t.Errorf("Unexpected results %s, %s", batch[0].Result, batch[2].Result)
+	}
+
+	assertRpcError(t, batch[1], rgin.JsonRpcInternalError)
+	if string(batch[1].ID) != "2" {
+		t.Errorf("Expected id 2, got %s", batch[1].ID)
+	}
+
+	assertRpcError(t, batch[3], rgin.JsonRpcInvalidRequest)
+	if string(batch[3].ID) != "null" {
+		t.Errorf("Expected null id, got %s", batch[3].ID)
+	}
+
+	if notified != 1 {
+		t.Errorf("Expected 1 notification, got %d", notified)
+	}
+}
+
+func TestJsonRpcServerErrors(t *testing.T) {
+	tests := []struct {
+		status api.ApiError
+		code   int
+	}{
+		{api.ApiErrorEntityDoesNotExists, rgin.JsonRpcServerError - 1},
+		{api.ApiErrorAuthFailed, rgin.JsonRpcServerError - 6},
+		{rgin.ApiErrorConflict, rgin.JsonRpcServerError - 50},
+		{rgin.ApiErrorUnavailable, rgin.JsonRpcServerError - 53},
+		{rgin.ApiErrorConflict + 60, rgin.JsonRpcServerErrorMin},
+	}
+
+	for _, test := range tests {
+		log := apitest.NewLogger()
+		g := rgin.New(log)
+		rpc := rgin.NewJsonRpc(log)
+
+		status := test.status
+		rpc.Register("fail", rgin.JsonRpcConfig{}, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			return apitest.Fail(status, "Failed", errors.New("cause"))
+		})
+		if err := g.AddJsonRpc("/rpc", rpc); err != nil {
+			t.Fatal(err)
+		}
+
+		res := rpcCall(t, apitest.New(g), `{"jsonrpc":"2.0","method":"fail","id":1}`)
+		assertRpcError(t, res, test.code)
+
+		// the data carries the api error model
+		data, _ := json.Marshal(res.Error.Data)
+		var model api.ErrorModel
+		if err := json.Unmarshal(data, &model); err != nil || model.Code != test.status || model.DevMsg != "cause" {
+			t.Errorf("Unexpected error data %s. %v", data, err)
+		}
+	}
+}
+
+func TestJsonRpcUnsupportedTenant(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Register accepted a tenant source not supported by JSON-RPC")
+		}
+	}()
+
+	rgin.NewJsonRpc(apitest.NewLogger()).Register("m", rgin.JsonRpcConfig{Tenant: api.ConfigTenant(0x2)}, nil)
+}
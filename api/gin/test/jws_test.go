@@ -0,0 +1,103 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+func TestDetachedJwsRoundTrip(t *testing.T) {
+	module := newTestModule(t)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	module.signers["ed"] = edKey
+
+	payload := []byte(`{"error":{"code":0},"data":"hello"}`)
+
+	for _, alias := range []string{"rsa", "ec", "ed"} {
+		t.Run(alias, func(t *testing.T) {
+			signer := module.signers[alias]
+
+			jws, err := rgin.SignDetachedJws(signer, alias, payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := rgin.VerifyDetachedJws(jws, payload, signer.Public()); err != nil {
+				t.Errorf("Valid signature rejected. %v", err)
+			}
+
+			if err := rgin.VerifyDetachedJws(jws, append(payload, ' '), signer.Public()); !errors.Is(err, rgin.ErrInvalidJws) {
+				t.Errorf("Signature of a modified payload accepted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDetachedJwsRejectsOtherKeys(t *testing.T) {
+	module := newTestModule(t)
+	payload := []byte(`{}`)
+
+	jws, err := rgin.SignDetachedJws(module.signers["rsa"], "rsa", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the algorithm of the header does not match an ECDSA key
+	if err := rgin.VerifyDetachedJws(jws, payload, module.signers["ec"].Public()); !errors.Is(err, rgin.ErrInvalidJws) {
+		t.Errorf("Signature verified with another key, got %v", err)
+	}
+
+	other := newTestModule(t)
+	if err := rgin.VerifyDetachedJws(jws, payload, other.signers["rsa"].Public()); !errors.Is(err, rgin.ErrInvalidJws) {
+		t.Errorf("Signature verified with another RSA key, got %v", err)
+	}
+
+	for _, malformed := range []string{"", "a.b.c", jws[:len(jws)-4], "e30.." + "AAAA"} {
+		if err := rgin.VerifyDetachedJws(malformed, payload, module.signers["rsa"].Public()); err == nil {
+			t.Errorf("Malformed JWS %q accepted", malformed)
+		}
+	}
+}
+
+func TestSignedResponses(t *testing.T) {
+	log := apitest.NewLogger()
+	module := newTestModule(t)
+	h := apitest.New(rgin.New(log))
+
+	service := func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok("hello")
+	}
+
+	for _, alias := range []string{"rsa", "ec", "missing"} {
+		config := rgin.NewConfigBuilder(log).SignResponses(rgin.JwsConfig{Module: module, KeyAlias: alias}).Build()
+		if err := h.AddRoute(http.MethodGet, "/"+alias, config, service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, alias := range []string{"rsa", "ec"} {
+		r := h.Get("/"+alias).Do().AssertStatus(t, http.StatusOK)
+
+		jws := r.Header().Get("X-Jws-Signature")
+		if err := rgin.VerifyDetachedJws(jws, r.Recorder.Body.Bytes(), module.signers[alias].Public()); err != nil {
+			t.Errorf("Response signed with %s not verified. %v", alias, err)
+		}
+	}
+
+	// responses are never sent unsigned
+	r := h.Get("/missing").Do().AssertStatus(t, http.StatusInternalServerError).AssertErrorCode(t, api.ApiErrorUnexpected)
+	if r.Header().Get("X-Jws-Signature") != "" {
+		t.Error("Failure response carries a signature")
+	}
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"testing"
+
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+func TestUnixListener(t *testing.T) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("Unix socket permissions are not supported on Windows")
+	}
+
+	dir, err := ioutil.TempDir("", "listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "api.sock")
+
+	listener, err := rgin.UnixListener(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 || info.Mode()&os.ModeSocket == 0 {
+		t.Fatalf("Unexpected socket %v. %v", info, err)
+	}
+
+	if listener.Addr().String() != path {
+		t.Errorf("Expected address %s, got %s", path, listener.Addr())
+	}
+
+	if conn, err := net.Dial("unix", path); err != nil {
+		t.Errorf("Socket not reachable. %v", err)
+	} else {
+		conn.Close()
+	}
+
+	// a socket still served is never taken over
+	if _, err := rgin.UnixListener(path, 0600); err == nil {
+		t.Error("UnixListener replaced a socket in use")
+	}
+
+	listener.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Close left the socket behind. %v", err)
+	}
+
+	// a stale socket of a previous run is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err = rgin.UnixListener(path, 0660)
+	if err != nil {
+		t.Fatalf("Stale socket not replaced. %v", err)
+	}
+	defer listener.Close()
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("Unexpected socket %v. %v", info, err)
+	}
+
+	// the private directory used to create the socket is removed
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only the socket in %s, got %d entries", dir, len(entries))
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rgin.UnixListener(file, 0600); err == nil {
+		t.Error("UnixListener replaced a regular file")
+	}
+}
@@ -0,0 +1,208 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+type patchAddress struct {
+	Secret string `json:"-"`
+	City   string `json:"city"`
+}
+
+type patchEntity struct {
+	ID      int `json:"-"`
+	version int
+	Name    string       `json:"name"`
+	Tags    []string     `json:"tags,omitempty"`
+	Address patchAddress `json:"address"`
+}
+
+func assertJSON(t *testing.T, got []byte, expected string) {
+	t.Helper()
+
+	var gotValue, expectedValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("Invalid JSON %s. %v", got, err)
+	}
+	json.Unmarshal([]byte(expected), &expectedValue)
+
+	if !reflect.DeepEqual(gotValue, expectedValue) {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestJsonPatchApplyJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		patch    string
+		doc      string
+		expected string
+	}{
+		{"add member", `[{"op":"add","path":"/b","value":2}]`, `{"a":1}`, `{"a":1,"b":2}`},
+		{"append", `[{"op":"add","path":"/tags/-","value":"z"}]`, `{"tags":["x"]}`, `{"tags":["x","z"]}`},
+		{"insert", `[{"op":"add","path":"/tags/0","value":"z"}]`, `{"tags":["x"]}`, `{"tags":["z","x"]}`},
+		{"remove", `[{"op":"remove","path":"/tags/0"}]`, `{"tags":["x","y"]}`, `{"tags":["y"]}`},
+		{"replace", `[{"op":"replace","path":"/a","value":"v"}]`, `{"a":1}`, `{"a":"v"}`},
+		{"move", `[{"op":"move","from":"/a","path":"/b"}]`, `{"a":{"k":1}}`, `{"b":{"k":1}}`},
+		{"copy", `[{"op":"copy","from":"/a","path":"/b"}]`, `{"a":[1]}`, `{"a":[1],"b":[1]}`},
+		{"test", `[{"op":"test","path":"/a","value":1.0},{"op":"remove","path":"/a"}]`, `{"a":1}`, `{}`},
+		{"escaped path", `[{"op":"replace","path":"/a~1b/c~0d","value":2}]`, `{"a/b":{"c~d":1}}`, `{"a/b":{"c~d":2}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patch, err := rgin.ParseJsonPatch([]byte(test.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			out, err := patch.ApplyJSON([]byte(test.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertJSON(t, out, test.expected)
+		})
+	}
+}
+
+func TestJsonPatchErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		patch  string
+		doc    string
+		err    error
+		status api.ApiError
+	}{
+		{"failed test", `[{"op":"test","path":"/a","value":2}]`, `{"a":1}`, rgin.ErrPatchTestFailed, rgin.ApiErrorConflict},
+		{"missing member", `[{"op":"remove","path":"/b"}]`, `{"a":1}`, rgin.ErrPatchInvalidPath, api.ApiErrorUnknownItemRequested},
+		{"index out of range", `[{"op":"add","path":"/tags/5","value":1}]`, `{"tags":[]}`, rgin.ErrPatchInvalidPath, api.ApiErrorUnknownItemRequested},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patch, err := rgin.ParseJsonPatch([]byte(test.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = patch.ApplyJSON([]byte(test.doc))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Expected %v, got %v", test.err, err)
+			}
+
+			if status := rgin.PatchErrorStatus(err); status != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, status)
+			}
+		})
+	}
+}
+
+func TestParseJsonPatchRejectsInvalidOperations(t *testing.T) {
+	for _, doc := range []string{
+		`{"op":"add"}`,
+		`[{"op":"unknown","path":"/a"}]`,
+		`[{"op":"add","path":"a","value":1}]`,
+		`[{"op":"move","path":"/a"}]`,
+		`[{"op":"move","from":"/a","path":"/a/b"}]`,
+	} {
+		if _, err := rgin.ParseJsonPatch([]byte(doc)); err == nil {
+			t.Errorf("ParseJsonPatch(%s) succeeded, expected an error", doc)
+		}
+	}
+}
+
+func TestPatchKeepsHiddenFields(t *testing.T) {
+	entity := patchEntity{ID: 7, version: 3, Name: "a", Tags: []string{"x"}, Address: patchAddress{Secret: "s", City: "c"}}
+
+	patch, err := rgin.ParseJsonPatch([]byte(`[{"op":"remove","path":"/tags"},{"op":"replace","path":"/name","value":"b"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := patch.Apply(&entity); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := patchEntity{ID: 7, version: 3, Name: "b", Address: patchAddress{Secret: "s", City: "c"}}
+	if !reflect.DeepEqual(entity, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, entity)
+	}
+}
+
+func TestPatchLeavesTargetUnchangedOnFailure(t *testing.T) {
+	entity := patchEntity{ID: 7, Name: "a", Tags: []string{"x"}}
+
+	patch, err := rgin.ParseJsonPatch([]byte(`[{"op":"replace","path":"/name","value":"b"},{"op":"test","path":"/tags/0","value":"y"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := patch.Apply(&entity); !errors.Is(err, rgin.ErrPatchTestFailed) {
+		t.Fatalf("Expected a failed test, got %v", err)
+	}
+
+	if entity.Name != "a" {
+		t.Errorf("Target changed by a failed patch: %+v", entity)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	entity := patchEntity{ID: 7, Name: "a", Tags: []string{"x"}, Address: patchAddress{Secret: "s", City: "c"}}
+
+	patch, err := rgin.ParseMergePatch([]byte(`{"tags":null,"address":{"city":"d"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := patch.Apply(&entity); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := patchEntity{ID: 7, Name: "a", Address: patchAddress{Secret: "s", City: "d"}}
+	if !reflect.DeepEqual(entity, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, entity)
+	}
+}
+
+func TestJsonPatchRoute(t *testing.T) {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+
+	err := h.AddRoute(http.MethodPatch, "/items/1", rgin.NewConfigBuilder(log).JsonPatch().Build(),
+		func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			entity := patchEntity{Name: "a"}
+			if err := input.Model().(rgin.Patch).Apply(&entity); err != nil {
+				return rgin.NewPatchErrorOutput(err)
+			}
+			return apitest.Ok(entity)
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entity patchEntity
+	h.Patch("/items/1").Body("application/json-patch+json", []byte(`[{"op":"replace","path":"/name","value":"b"}]`)).Do().
+		AssertStatus(t, http.StatusOK).
+		AssertData(t, &entity)
+
+	if entity.Name != "b" {
+		t.Errorf("Expected patched name b, got %s", entity.Name)
+	}
+
+	h.Patch("/items/1").Body("application/json-patch+json", []byte(`[{"op":"test","path":"/name","value":"z"}]`)).Do().
+		AssertStatus(t, http.StatusConflict).
+		AssertErrorCode(t, rgin.ApiErrorConflict)
+
+	h.Patch("/items/1").Body("text/plain", []byte(`[]`)).Do().AssertStatus(t, http.StatusUnsupportedMediaType)
+	h.Patch("/items/1").Body("application/json-patch+json", []byte(`[{"op":"nope"}]`)).Do().AssertStatus(t, http.StatusBadRequest)
+}
@@ -0,0 +1,151 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/abstracte/tenant"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+func TestCompilePolicyRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{`tenant ==`, `has_role("admin"`, `"unterminated`, `tenant user`, `(tenant == "a"`, ``} {
+		if _, err := rgin.CompilePolicy(expr); err == nil {
+			t.Errorf("CompilePolicy(%q) succeeded, expected an error", expr)
+		}
+	}
+}
+
+func TestPolicyAllow(t *testing.T) {
+	input := rgin.PolicyInput{
+		Tenant:  "t1",
+		User:    "u1",
+		Roles:   []string{"editor"},
+		Scopes:  []string{"items:read"},
+		Params:  map[string]string{"tenant": "t1"},
+		Headers: map[string]string{"X-Env": "dev"},
+	}
+
+	tests := []struct {
+		expr  string
+		allow bool
+	}{
+		{`tenant == "t1"`, true},
+		{`tenant != "t1"`, false},
+		{`tenant == param["tenant"] && user == "u1"`, true},
+		{`header["X-Env"] in ["dev", "test"]`, true},
+		{`header["X-Env"] in ["prod"]`, false},
+		{`has_role("editor") && has_scope("items:read")`, true},
+		{`has_role("admin") || has_scope("items:write")`, false},
+		{`!has_role("admin")`, true},
+		{`(tenant == "x" || user == "u1") && true`, true},
+		{`"text"`, false},
+	}
+
+	for _, test := range tests {
+		policy, err := rgin.CompilePolicy(test.expr)
+		if err != nil {
+			t.Fatalf("CompilePolicy(%q) failed. %v", test.expr, err)
+		}
+
+		if allow := policy.Allow(input); allow != test.allow {
+			t.Errorf("%q allowed %v, expected %v", test.expr, allow, test.allow)
+		}
+	}
+}
+
+func TestPolicyDeniesMissingAttributes(t *testing.T) {
+	// an absent parameter or header must not compare equal to another absent one
+	tests := []string{
+		`param["tenant"] == header["X-Tenant"]`,
+		`param["tenant"] != "t1"`,
+		`!(param["tenant"] == "t1")`,
+		`header["X-Env"] in ["", "dev"]`,
+		`tenant == user`,
+	}
+
+	for _, expr := range tests {
+		policy, err := rgin.CompilePolicy(expr)
+		if err != nil {
+			t.Fatalf("CompilePolicy(%q) failed. %v", expr, err)
+		}
+
+		if policy.Allow(rgin.PolicyInput{}) {
+			t.Errorf("%q allowed a request missing its attributes", expr)
+		}
+	}
+}
+
+func TestAuthorizePolicyRoute(t *testing.T) {
+	log := apitest.NewLogger()
+	g := rgin.New(log)
+	h := apitest.New(g)
+
+	provider := rgin.RoleProviderFunc(func(logCtx logging.Context, t tenant.Context) ([]string, error) {
+		if t.UserID() == "admin" {
+			return []string{"admin"}, nil
+		}
+		return nil, nil
+	})
+
+	config := rgin.NewConfigBuilder(log).
+		Authorize(rgin.AuthorizationConfig{
+			Provider: provider,
+			Policy:   `has_role("admin") || (tenant == param["tenant"] && header["X-Env"] in ["dev", "test"])`,
+		}).
+		Tenant(api.ConfigTenantFromHeaders).
+		Build()
+
+	err := h.AddRoute(http.MethodGet, "/tenants/:tenant", config, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok(ctx.Tenant().ID())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Get("/tenants/t1").Tenant("t1", "u1").Header("X-Env", "dev").Do().AssertStatus(t, http.StatusOK)
+	h.Get("/tenants/t1").Tenant("t1", "u1").Header("X-Env", "prod").Do().AssertStatus(t, http.StatusForbidden)
+	h.Get("/tenants/t1").Tenant("t2", "u1").Header("X-Env", "dev").Do().AssertStatus(t, http.StatusForbidden)
+	h.Get("/tenants/t1").Tenant("t2", "admin").Do().AssertStatus(t, http.StatusOK)
+}
+
+func TestAuthorizeRequiresProviderForRoles(t *testing.T) {
+	log := apitest.NewLogger()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Authorize accepted a has_role policy without a RoleProvider")
+		}
+	}()
+
+	rgin.NewConfigBuilder(log).Authorize(rgin.AuthorizationConfig{Policy: `has_scope("items:read") || has_role("admin")`})
+}
+
+func TestAuthorizeScopesFromApiKey(t *testing.T) {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+
+	store := rgin.NewMemoryApiKeyStore()
+	store.Add("reader", rgin.ApiKey{TenantID: "t1", UserID: "u1", Scopes: []string{"items:read"}})
+	store.Add("writer", rgin.ApiKey{TenantID: "t1", UserID: "u2", Scopes: []string{"items:write"}})
+
+	// scopes come from the API key, no role provider is needed
+	config := rgin.NewConfigBuilder(log).
+		Authorize(rgin.AuthorizationConfig{Policy: `has_scope("items:read")`}).
+		TenantFromApiKey(rgin.ApiKeyConfig{Store: store}).
+		Build()
+
+	err := h.AddRoute(http.MethodGet, "/items", config, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok(ctx.Tenant().UserID())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Get("/items").Header("X-Api-Key", "reader").Do().AssertStatus(t, http.StatusOK)
+	h.Get("/items").Header("X-Api-Key", "writer").Do().AssertStatus(t, http.StatusForbidden).AssertErrorCode(t, api.ApiErrorAuthFailed)
+}
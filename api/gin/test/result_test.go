@@ -0,0 +1,42 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+func TestResult(t *testing.T) {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+
+	outputs := map[string]api.ServiceOutput{
+		"/ok":       apitest.Ok("hello"),
+		"/missing":  apitest.Output{Code: api.ApiErrorEntityDoesNotExists, Message: "No item"},
+		"/conflict": apitest.Fail(api.ApiErrorEntityAlreadyExists, "Duplicate", nil),
+	}
+
+	for path, output := range outputs {
+		out := output
+		err := h.AddRoute(http.MethodGet, path, rgin.NewConfigBuilder(log).Build(), func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			return out
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var data string
+	h.Get("/ok").CorrID("c1").Do().AssertStatus(t, http.StatusOK).AssertCorrID(t, "c1").AssertData(t, &data)
+	if data != "hello" {
+		t.Errorf("Expected hello, got %s", data)
+	}
+
+	// a failed output without an error must still be reported, not panic
+	h.Get("/missing").Do().AssertStatus(t, http.StatusNotFound).AssertErrorCode(t, api.ApiErrorEntityDoesNotExists)
+	h.Get("/conflict").Do().AssertStatus(t, http.StatusConflict).AssertErrorCode(t, api.ApiErrorEntityAlreadyExists)
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	log := apitest.NewLogger()
+
+	if _, err := rgin.NewWithOptions(log, rgin.ServerOptions{TrustedProxies: []string{"not an address"}}); err == nil {
+		t.Error("NewWithOptions accepted invalid trusted proxies")
+	}
+
+	g, err := rgin.NewWithOptions(log, rgin.ServerOptions{TrustedProxies: []string{"192.0.2.0/24"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the gin mode is left to the caller
+	if gin.Mode() != gin.TestMode {
+		t.Errorf("NewWithOptions changed the gin mode to %s", gin.Mode())
+	}
+
+	h := apitest.New(g)
+	err = h.AddRoute(http.MethodGet, "/ip", rgin.NewConfigBuilder(log).Build(), func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return apitest.Ok(input.RawCtx().(*gin.Context).ClientIP())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the recorded requests come from 192.0.2.1, a trusted proxy
+	var ip string
+	h.Get("/ip").Header("X-Forwarded-For", "203.0.113.7").Do().AssertStatus(t, http.StatusOK).AssertData(t, &ip)
+	if ip != "203.0.113.7" {
+		t.Errorf("Expected the forwarded client IP, got %s", ip)
+	}
+}
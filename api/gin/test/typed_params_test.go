@@ -0,0 +1,102 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/rem/api/apitest"
+	rgin "github.com/hellcats88/rem/api/gin"
+)
+
+type itemParams struct {
+	Kind string    `param:"kind" enum:"book,disc"`
+	Code string    `param:"code" pattern:"[A-Z]{3}|[0-9]{4}"`
+	ID   int64     `param:"id"`
+	Ref  uuid.UUID `param:"ref"`
+}
+
+func newTypedParamsHarness(t *testing.T) *apitest.Harness {
+	log := apitest.NewLogger()
+	h := apitest.New(rgin.New(log))
+
+	err := h.AddRoute(http.MethodGet, "/items/:kind/:code/:id/:ref", rgin.NewConfigBuilder(log).TypedParams(itemParams{}).Build(),
+		func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			return apitest.Ok(input.(rgin.ServiceInput).TypedParams())
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return h
+}
+
+func TestTypedParamsConverted(t *testing.T) {
+	h := newTypedParamsHarness(t)
+	ref := uuid.New()
+
+	var params itemParams
+	h.Get("/items/book/ABC/42/"+ref.String()).Do().AssertStatus(t, http.StatusOK).AssertData(t, &params)
+
+	expected := itemParams{Kind: "book", Code: "ABC", ID: 42, Ref: ref}
+	if params != expected {
+		t.Errorf("Expected %+v, got %+v", expected, params)
+	}
+
+	h.Get("/items/disc/1234/42/"+ref.String()).Do().AssertStatus(t, http.StatusOK)
+}
+
+func TestTypedParamsRejected(t *testing.T) {
+	h := newTypedParamsHarness(t)
+	ref := uuid.New().String()
+
+	tests := map[string]string{
+		"pattern prefix":         "/items/book/ABCD/42/" + ref,
+		"pattern suffix":         "/items/book/x1234/42/" + ref,
+		"pattern other branch":   "/items/book/ABC1234/42/" + ref,
+		"not an integer":         "/items/book/ABC/x/" + ref,
+		"integer out of range":   "/items/book/ABC/99999999999999999999/" + ref,
+		"not an uuid":            "/items/book/ABC/42/nope",
+		"pattern case sensitive": "/items/book/abc/42/" + ref,
+	}
+
+	for name, path := range tests {
+		t.Run(name, func(t *testing.T) {
+			h.Get(path).Do().AssertStatus(t, http.StatusBadRequest)
+		})
+	}
+
+	// values outside of an enum address an unknown resource
+	h.Get("/items/game/ABC/42/"+ref).Do().AssertStatus(t, http.StatusNotFound).AssertErrorCode(t, api.ApiErrorEntityDoesNotExists)
+}
+
+func TestTypedParamsInvalidDeclarations(t *testing.T) {
+	log := apitest.NewLogger()
+
+	tests := map[string]interface{}{
+		"not a struct": "",
+		"enum on integer": struct {
+			ID int `param:"id" enum:"1,2"`
+		}{},
+		"invalid pattern": struct {
+			Code string `param:"code" pattern:"("`
+		}{},
+		"unsupported type": struct {
+			At float64 `param:"at"`
+		}{},
+	}
+
+	for name, model := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("TypedParams accepted an invalid declaration")
+				}
+			}()
+
+			rgin.NewConfigBuilder(log).TypedParams(model)
+		})
+	}
+}
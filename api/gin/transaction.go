@@ -1,13 +1,17 @@
 package gin
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hellcats88/abstracte/api"
 	"github.com/hellcats88/abstracte/logging"
 	"github.com/hellcats88/abstracte/runtime"
 	"github.com/hellcats88/abstracte/storage"
+	rstorage "github.com/hellcats88/rem/storage"
 )
 
 type txNoOp struct{}
@@ -16,10 +20,84 @@ func (txNoOp) Ref() interface{}                    { return nil }
 func (txNoOp) Commit() error                       { return nil }
 func (txNoOp) Rollback() error                     { return nil }
 func (txNoOp) Begin() (storage.Transaction, error) { return nil, nil }
+func (txNoOp) Query() interface{}                  { return nil }
 
 type transactionHandler struct {
-	log logging.Logger
-	db  storage.Context
+	log     logging.Logger
+	db      storage.Context
+	breaker *CircuitBreaker
+	options *rstorage.TxOptions
+}
+
+// txOptions returns the options of the route. Without explicit options, GET and HEAD
+// routes open read-only transactions
+func (g transactionHandler) txOptions(method string) rstorage.TxOptions {
+	if g.options != nil {
+		return *g.options
+	}
+	return rstorage.TxOptions{ReadOnly: method == http.MethodGet || method == http.MethodHead}
+}
+
+// opener returns the function opening the transaction of the request, with the route options
+// when the storage supports them. cancel releases the deadline once the transaction is over
+func (g transactionHandler) opener(ctx *gin.Context, managed bool) (open func() (storage.Transaction, error), cancel context.CancelFunc) {
+	return g.openerFor(ctx.Request.Context(), ctx.Request.Method, managed)
+}
+
+// openerFor is opener for a transaction bound to reqCtx, e.g. the one of a job
+func (g transactionHandler) openerFor(reqCtx context.Context, method string, managed bool) (open func() (storage.Transaction, error), cancel context.CancelFunc) {
+	db, ok := g.db.(rstorage.OptionsContext)
+	if !ok {
+		if managed {
+			return g.db.Tx, func() {}
+		}
+		return g.db.UnmanagedTx, func() {}
+	}
+
+	options := g.txOptions(method)
+
+	txCtx, cancel := reqCtx, context.CancelFunc(func() {})
+	if options.Timeout > 0 {
+		txCtx, cancel = context.WithTimeout(txCtx, options.Timeout)
+	}
+
+	if managed {
+		return func() (storage.Transaction, error) { return db.TxWithOptions(txCtx, options) }, cancel
+	}
+	return func() (storage.Transaction, error) { return db.UnmanagedTxWithOptions(txCtx, options) }, cancel
+}
+
+// breakerOpenError is returned by open while the circuit breaker rejects the transactions
+type breakerOpenError struct {
+	retryAfter time.Duration
+}
+
+func (e breakerOpenError) Error() string {
+	return "Circuit breaker open"
+}
+
+// open opens a transaction through the circuit breaker, if any. Failures caused by the
+// client going away (reqCtx done) are not storage failures and don't count on the breaker
+func (g transactionHandler) open(reqCtx context.Context, logCtx logging.Context, open func() (storage.Transaction, error)) (storage.Transaction, error) {
+	if g.breaker == nil {
+		return open()
+	}
+
+	if allowed, retryAfter := g.breaker.allow(logCtx); !allowed {
+		return nil, breakerOpenError{retryAfter: retryAfter}
+	}
+
+	tx, err := open()
+	switch {
+	case err == nil:
+		g.breaker.success(logCtx)
+	case errors.Is(err, context.Canceled) || reqCtx.Err() != nil:
+		g.breaker.abandon()
+	default:
+		g.breaker.failure(logCtx, err)
+	}
+
+	return tx, err
 }
 
 func (g transactionHandler) createNoTransaction(ctx *gin.Context) {
@@ -28,18 +106,34 @@ func (g transactionHandler) createNoTransaction(ctx *gin.Context) {
 }
 
 func (g transactionHandler) createManagedTransaction(ctx *gin.Context) {
-	tx, err := g.db.Tx()
+	// batch sub-requests share the transaction opened by the batch route
+	if tx, ok := batchTransaction(ctx); ok {
+		ctx.Set(api.TxKey, tx)
+		ctx.Next()
+		return
+	}
 
-	rCtx, _ := ctx.Get(api.RuntimeKey)
-	svcCtx := rCtx.(runtime.Context)
+	// logging key is always populated, don't check the exist return value.
+	// Runtime context is not available yet, it needs the transaction.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	open, cancel := g.opener(ctx, true)
+	defer cancel()
+
+	tx, err := g.open(ctx.Request.Context(), logCtx, open)
+	if open, ok := err.(breakerOpenError); ok {
+		g.breaker.reject(ctx, logCtx, open.retryAfter)
+		return
+	}
 
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, api.Model{
+		abortWithError(ctx, http.StatusUnauthorized, api.Model{
 			Error: api.ErrorModel{
 				Code:   api.ApiErrorUnexpected,
 				Msg:    "Failed to open new managed transaction",
 				DevMsg: err.Error(),
-				CorrId: svcCtx.Log().CorrID(),
+				CorrId: logCtx.CorrID(),
 			},
 		})
 
@@ -51,18 +145,27 @@ func (g transactionHandler) createManagedTransaction(ctx *gin.Context) {
 }
 
 func (g transactionHandler) createUnmanagedTransaction(ctx *gin.Context) {
-	tx, err := g.db.UnmanagedTx()
+	// logging key is always populated, don't check the exist return value.
+	// Runtime context is not available yet, it needs the transaction.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
 
-	rCtx, _ := ctx.Get(api.RuntimeKey)
-	svcCtx := rCtx.(runtime.Context)
+	open, cancel := g.opener(ctx, false)
+	defer cancel()
+
+	tx, err := g.open(ctx.Request.Context(), logCtx, open)
+	if open, ok := err.(breakerOpenError); ok {
+		g.breaker.reject(ctx, logCtx, open.retryAfter)
+		return
+	}
 
 	if err != nil {
-		ctx.AbortWithStatusJSON(http.StatusUnauthorized, api.Model{
+		abortWithError(ctx, http.StatusUnauthorized, api.Model{
 			Error: api.ErrorModel{
 				Code:   api.ApiErrorUnexpected,
 				Msg:    "Failed to open new unmanaged transaction",
 				DevMsg: err.Error(),
-				CorrId: svcCtx.Log().CorrID(),
+				CorrId: logCtx.CorrID(),
 			},
 		})
 
@@ -76,6 +179,11 @@ func (g transactionHandler) createUnmanagedTransaction(ctx *gin.Context) {
 func (g transactionHandler) createCommitTx(ctx *gin.Context) {
 	ctx.Next()
 
+	// the batch route commits or rolls back once all its sub-requests completed
+	if _, ok := batchTransaction(ctx); ok {
+		return
+	}
+
 	result, _ := ctx.Get(api.ServiceResultKey)
 	svcRes := result.(api.ServiceOutput)
 
@@ -88,7 +196,7 @@ func (g transactionHandler) createCommitTx(ctx *gin.Context) {
 	if svcRes.Status() != api.ApiErrorNoError {
 		err := svcTx.Rollback()
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, api.Model{
+			abortWithError(ctx, http.StatusInternalServerError, api.Model{
 				Error: api.ErrorModel{
 					Code:   api.ApiErrorUnexpected,
 					Msg:    "Failed to rollback changes",
@@ -102,7 +210,7 @@ func (g transactionHandler) createCommitTx(ctx *gin.Context) {
 	} else {
 		err := svcTx.Commit()
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusInternalServerError, api.Model{
+			abortWithError(ctx, http.StatusInternalServerError, api.Model{
 				Error: api.ErrorModel{
 					Code:   api.ApiErrorUnexpected,
 					Msg:    "Failed to commit changes",
@@ -0,0 +1,196 @@
+package gin
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+)
+
+var uuidType = reflect.TypeOf(uuid.UUID{})
+
+// typedParam is the declaration of a path parameter bound to a struct field
+type typedParam struct {
+	name    string
+	field   int
+	enum    []string
+	pattern *regexp.Regexp
+}
+
+type typedParamsHandler struct {
+	model  reflect.Type
+	params []typedParam
+}
+
+// newTypedParamsHandler reads the path parameter declarations from the fields of model.
+// Each field tagged with param:"name" is bound to the path parameter name and converted
+// to the field type: string, bool, signed or unsigned integers and uuid.UUID.
+// String fields can be further restricted with enum:"a,b,c" or pattern:"regexp" tags,
+// the pattern matching the whole parameter.
+// Invalid declarations panic, since they are programming errors detected at startup
+func newTypedParamsHandler(model interface{}) typedParamsHandler {
+	t := reflect.TypeOf(model)
+	if t.Kind() != reflect.Struct {
+		panic("Typed params model must be a struct")
+	}
+
+	handler := typedParamsHandler{model: t}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("param")
+		if name == "" {
+			continue
+		}
+
+		param := typedParam{name: name, field: i}
+
+		if enum := field.Tag.Get("enum"); enum != "" {
+			param.enum = strings.Split(enum, ",")
+		}
+
+		if pattern := field.Tag.Get("pattern"); pattern != "" {
+			// the whole parameter must match, not just a part of it
+			param.pattern = regexp.MustCompile("^(?:" + pattern + ")$")
+		}
+
+		if (param.enum != nil || param.pattern != nil) && field.Type.Kind() != reflect.String {
+			panic(fmt.Sprintf("Typed param %s: enum and pattern need a string field", name))
+		}
+
+		if !convertible(field.Type) {
+			panic(fmt.Sprintf("Typed param %s: unsupported field type %s", name, field.Type))
+		}
+
+		handler.params = append(handler.params, param)
+	}
+
+	return handler
+}
+
+func convertible(t reflect.Type) bool {
+	if t == uuidType {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return false
+}
+
+func convertParam(field reflect.Value, value string) error {
+	if field.Type() == uuidType {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(id))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	}
+
+	return nil
+}
+
+func (g typedParamsHandler) loadTypedParams(ctx *gin.Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	model := reflect.New(g.model)
+	params := make(map[string]string)
+
+	for _, p := range g.params {
+		pV := ctx.Param(p.name)
+		if pV == "" {
+			abortWithError(ctx, http.StatusNotFound, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorMissingRequiredItem,
+					Msg:    "Missing part of URL",
+					DevMsg: fmt.Sprintf("Cannot find parameter %s", p.name),
+					CorrId: logCtx.CorrID(),
+				},
+			})
+			return
+		}
+
+		if p.enum != nil && !contains(p.enum, pV) {
+			abortWithError(ctx, http.StatusNotFound, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorEntityDoesNotExists,
+					Msg:    "Unknown part of URL",
+					DevMsg: fmt.Sprintf("Parameter %s must be one of %s, got %s", p.name, strings.Join(p.enum, ", "), pV),
+					CorrId: logCtx.CorrID(),
+				},
+			})
+			return
+		}
+
+		if p.pattern != nil && !p.pattern.MatchString(pV) {
+			abortWithError(ctx, http.StatusBadRequest, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorUnknownItemRequested,
+					Msg:    "Invalid part of URL",
+					DevMsg: fmt.Sprintf("Parameter %s does not match %s", p.name, p.pattern.String()),
+					CorrId: logCtx.CorrID(),
+				},
+			})
+			return
+		}
+
+		if err := convertParam(model.Elem().Field(p.field), pV); err != nil {
+			abortWithError(ctx, http.StatusBadRequest, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorUnknownItemRequested,
+					Msg:    "Invalid part of URL",
+					DevMsg: fmt.Sprintf("Cannot convert parameter %s. %v", p.name, err),
+					CorrId: logCtx.CorrID(),
+				},
+			})
+			return
+		}
+
+		params[p.name] = pV
+	}
+
+	ctx.Set(api.InputParamsKey, params)
+	ctx.Set(TypedParamsKey, model.Interface())
+	ctx.Next()
+}
@@ -0,0 +1,63 @@
+package gin
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bufferedWriter holds status and body of the response in memory so that
+// a stage can inspect or transform them before they are sent to the client.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func newBufferedWriter(w gin.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{ResponseWriter: w, status: w.Status()}
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+func (w *bufferedWriter) Bytes() []byte {
+	return w.body.Bytes()
+}
+
+// flush sends the buffered status and the given body to the wrapped writer
+func (w *bufferedWriter) flush(body []byte) error {
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(body) == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return nil
+	}
+
+	_, err := w.ResponseWriter.Write(body)
+	return err
+}
@@ -0,0 +1,9 @@
+module github.com/hellcats88/rem/api/grpc
+
+go 1.15
+
+require (
+	github.com/hellcats88/abstracte v0.0.0-20210531083423-6e6de49b09ed
+	github.com/hellcats88/rem v0.0.0-20210317090745-a31d710583e4
+	google.golang.org/grpc v1.38.0
+)
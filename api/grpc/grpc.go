@@ -0,0 +1,216 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+
+	"github.com/hellcats88/abstracte/api"
+	alog "github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/storage"
+	atenant "github.com/hellcats88/abstracte/tenant"
+	"github.com/hellcats88/rem/logging"
+	"github.com/hellcats88/rem/runtime"
+	"github.com/hellcats88/rem/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MetadataCorrID is the metadata key carrying the correlation ID, in both directions
+	MetadataCorrID = "x-correlation-id"
+
+	// MetadataTenantID is the metadata key carrying the tenant ID
+	MetadataTenantID = "x-tenant-id"
+
+	// MetadataTenantUserID is the metadata key carrying the tenant user ID
+	MetadataTenantUserID = "x-tenant-userid"
+
+	// MetadataApiError is the trailer key carrying the api.ApiError of a failed call
+	MetadataApiError = "x-api-error"
+
+	// MetadataDevMsg is the trailer key carrying the detailed error message of a failed call
+	MetadataDevMsg = "x-api-devmsg"
+)
+
+// MethodConfig defines the pipeline of a gRPC method
+type MethodConfig struct {
+	// Zero value of the request message type, e.g. pb.GetItemRequest{}
+	Request interface{}
+
+	// Tenant source. ConfigTenantFromHeaders reads the tenant from the call metadata
+	Tenant api.ConfigTenant
+
+	// Transaction mode. Zero means no transaction
+	Tx api.ConfigTx
+}
+
+type txNoOp struct{}
+
+func (txNoOp) Ref() interface{}                    { return nil }
+func (txNoOp) Commit() error                       { return nil }
+func (txNoOp) Rollback() error                     { return nil }
+func (txNoOp) Begin() (storage.Transaction, error) { return nil, nil }
+func (txNoOp) Query() interface{}                  { return nil }
+
+// Adapter exposes api.Service functions as the unary methods of a gRPC service
+type Adapter struct {
+	desc grpc.ServiceDesc
+	log  alog.Logger
+	db   storage.Context
+}
+
+// New creates an adapter for the fully qualified gRPC service name (e.g. "items.v1.Items").
+// db can be nil if no method uses transactions
+func New(serviceName string, log alog.Logger, db storage.Context) *Adapter {
+	return &Adapter{
+		desc: grpc.ServiceDesc{
+			ServiceName: serviceName,
+			HandlerType: (*interface{})(nil),
+			Streams:     []grpc.StreamDesc{},
+		},
+		log: log,
+		db:  db,
+	}
+}
+
+// AddMethod exposes service as the unary method name of the adapter service
+func (a *Adapter) AddMethod(name string, config MethodConfig, service api.Service) error {
+	if config.Request == nil {
+		return fmt.Errorf("Method %s needs a request message type", name)
+	}
+
+	if config.Tx != 0 && a.db == nil {
+		return fmt.Errorf("Method %s needs a storage context to open transactions", name)
+	}
+
+	fullMethod := "/" + a.desc.ServiceName + "/" + name
+	requestType := reflect.TypeOf(config.Request)
+
+	a.desc.Methods = append(a.desc.Methods, grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			request := reflect.New(requestType).Interface()
+			if err := dec(request); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "Failed to decode request message. %v", err)
+			}
+
+			invoke := func(ctx context.Context, request interface{}) (interface{}, error) {
+				return a.invoke(ctx, config, service, request)
+			}
+
+			if interceptor == nil {
+				return invoke(ctx, request)
+			}
+
+			return interceptor(ctx, request, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, invoke)
+		},
+	})
+
+	return nil
+}
+
+// Register adds the adapter service to a gRPC server. Methods must be added before
+func (a *Adapter) Register(server *grpc.Server) {
+	desc := a.desc
+	server.RegisterService(&desc, a)
+}
+
+// Listen creates a gRPC server with the adapter service and serves it on address:port
+func (a *Adapter) Listen(port int, address string, opts ...grpc.ServerOption) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", address, port))
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(opts...)
+	a.Register(server)
+	return server.Serve(listener)
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (a *Adapter) fail(ctx context.Context, logCtx alog.Context, code api.ApiError, msg string, devMsg string) error {
+	grpc.SetTrailer(ctx, metadata.Pairs(
+		MetadataCorrID, logCtx.CorrID(),
+		MetadataApiError, strconv.Itoa(int(code)),
+		MetadataDevMsg, devMsg,
+	))
+
+	return status.Error(Code(code), msg)
+}
+
+func (a *Adapter) openTx(tx api.ConfigTx) (storage.Transaction, error) {
+	switch tx {
+	case api.ConfigTxManaged:
+		return a.db.Tx()
+	case api.ConfigTxUnmanaged:
+		return a.db.UnmanagedTx()
+	}
+
+	return txNoOp{}, nil
+}
+
+func (a *Adapter) invoke(ctx context.Context, config MethodConfig, service api.Service, request interface{}) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var logCtx alog.Context
+	if corrID := first(md, MetadataCorrID); corrID != "" {
+		logCtx = logging.NewContext(corrID)
+	} else {
+		logCtx = logging.NewContextUUID()
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(MetadataCorrID, logCtx.CorrID()))
+
+	var tCtx atenant.Context
+	if config.Tenant == api.ConfigTenantFromHeaders {
+		tCtx = tenant.New(first(md, MetadataTenantID), first(md, MetadataTenantUserID))
+
+		if tCtx.ID() == "" || tCtx.UserID() == "" {
+			a.log.Error(logCtx, "Rejected call caused by missing tenant informations")
+			grpc.SetTrailer(ctx, metadata.Pairs(MetadataCorrID, logCtx.CorrID()))
+			return nil, status.Error(codes.Unauthenticated, "Failed to get user information")
+		}
+	} else {
+		tCtx = tenant.NewEmpty()
+	}
+
+	tx, err := a.openTx(config.Tx)
+	if err != nil {
+		return nil, a.fail(ctx, logCtx, api.ApiErrorUnexpected, "Failed to open new transaction", err.Error())
+	}
+
+	rCtx := runtime.New(logCtx, tx, tCtx)
+	svcRes := service(rCtx, grpcServiceInput{ctx: ctx, request: request, md: md})
+
+	if config.Tx == api.ConfigTxManaged {
+		if svcRes.Status() != api.ApiErrorNoError {
+			if err := tx.Rollback(); err != nil {
+				return nil, a.fail(ctx, logCtx, api.ApiErrorUnexpected, "Failed to rollback changes", err.Error())
+			}
+		} else if err := tx.Commit(); err != nil {
+			return nil, a.fail(ctx, logCtx, api.ApiErrorUnexpected, "Failed to commit changes", err.Error())
+		}
+	}
+
+	if svcRes.Status() != api.ApiErrorNoError {
+		devMsg := ""
+		if svcRes.Err() != nil {
+			devMsg = svcRes.Err().Error()
+		}
+
+		return nil, a.fail(ctx, logCtx, svcRes.Status(), svcRes.ErrMessage(), devMsg)
+	}
+
+	return svcRes.ResponseModel(), nil
+}
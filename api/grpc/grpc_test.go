@@ -0,0 +1,197 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/runtime"
+	rgrpc "github.com/hellcats88/rem/api/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(ctx logging.Context, msg string, params ...interface{})                 {}
+func (nopLogger) Debug(ctx logging.Context, msg string, params ...interface{})                {}
+func (nopLogger) Trace(ctx logging.Context, msg string, params ...interface{})                {}
+func (nopLogger) Error(ctx logging.Context, msg string, params ...interface{})                {}
+func (nopLogger) Warn(ctx logging.Context, msg string, params ...interface{})                 {}
+func (nopLogger) BeginMethod(ctx logging.Context)                                             {}
+func (nopLogger) BeginMethodParams(ctx logging.Context, format string, params ...interface{}) {}
+func (nopLogger) EndMethod(ctx logging.Context)                                               {}
+func (nopLogger) EndMethodParams(ctx logging.Context, format string, params ...interface{})   {}
+
+type output struct {
+	code api.ApiError
+	err  error
+	data interface{}
+}
+
+func (o output) Status() api.ApiError       { return o.code }
+func (o output) Err() error                 { return o.err }
+func (o output) ErrMessage() string         { return "Service failed" }
+func (o output) ResponseModel() interface{} { return o.data }
+
+// serve exposes the adapter on an in-memory listener and returns a client connection to it
+func serve(t *testing.T, adapter *rgrpc.Adapter) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	adapter.Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func addMethod(t *testing.T, adapter *rgrpc.Adapter, name string, config rgrpc.MethodConfig, service api.Service) {
+	t.Helper()
+
+	if err := adapter.AddMethod(name, config, service); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		status api.ApiError
+		code   codes.Code
+	}{
+		{api.ApiErrorNoError, codes.OK},
+		{api.ApiErrorAuthFailed, codes.PermissionDenied},
+		{api.ApiErrorEntityAlreadyExists, codes.AlreadyExists},
+		{api.ApiErrorEntityDoesNotExists, codes.NotFound},
+		{api.ApiErrorMissingRequiredItem, codes.InvalidArgument},
+		{api.ApiErrorUnknownItemRequested, codes.InvalidArgument},
+		{api.ApiErrorUnexpected, codes.Internal},
+		{api.ApiError(0x100), codes.Unknown},
+	}
+
+	for _, test := range tests {
+		if code := rgrpc.Code(test.status); code != test.code {
+			t.Errorf("Expected %s for status %d, got %s", test.code, test.status, code)
+		}
+	}
+}
+
+func TestTenantFromMetadata(t *testing.T) {
+	adapter := rgrpc.New("items.v1.Items", nopLogger{}, nil)
+	addMethod(t, adapter, "WhoAmI", rgrpc.MethodConfig{Request: wrapperspb.StringValue{}, Tenant: api.ConfigTenantFromHeaders},
+		func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			return output{data: wrapperspb.String(ctx.Tenant().ID() + "/" + ctx.Tenant().UserID())}
+		})
+	conn := serve(t, adapter)
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		code codes.Code
+		user string
+	}{
+		{"tenant", metadata.Pairs(rgrpc.MetadataTenantID, "t1", rgrpc.MetadataTenantUserID, "u1"), codes.OK, "t1/u1"},
+		{"missing user", metadata.Pairs(rgrpc.MetadataTenantID, "t1"), codes.Unauthenticated, ""},
+		{"missing tenant", metadata.Pairs(rgrpc.MetadataTenantUserID, "u1"), codes.Unauthenticated, ""},
+		{"no metadata", metadata.MD{}, codes.Unauthenticated, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			md := test.md.Copy()
+			md.Set(rgrpc.MetadataCorrID, "corr-1")
+			ctx := metadata.NewOutgoingContext(context.Background(), md)
+
+			var header, trailer metadata.MD
+			res := &wrapperspb.StringValue{}
+			err := conn.Invoke(ctx, "/items.v1.Items/WhoAmI", &wrapperspb.StringValue{}, res, grpc.Header(&header), grpc.Trailer(&trailer))
+
+			if code := status.Code(err); code != test.code {
+				t.Fatalf("Expected %s, got %v", test.code, err)
+			}
+
+			if res.GetValue() != test.user {
+				t.Errorf("Expected tenant %q, got %q", test.user, res.GetValue())
+			}
+
+			// the correlation ID of the caller is sent back
+			if corrID := header.Get(rgrpc.MetadataCorrID); len(corrID) != 1 || corrID[0] != "corr-1" {
+				t.Errorf("Unexpected correlation ID %v", corrID)
+			}
+		})
+	}
+}
+
+func TestServiceFailures(t *testing.T) {
+	tests := []struct {
+		status api.ApiError
+		code   codes.Code
+	}{
+		{api.ApiErrorEntityDoesNotExists, codes.NotFound},
+		{api.ApiErrorEntityAlreadyExists, codes.AlreadyExists},
+		{api.ApiErrorAuthFailed, codes.PermissionDenied},
+		{api.ApiErrorUnexpected, codes.Internal},
+	}
+
+	for _, test := range tests {
+		adapter := rgrpc.New("items.v1.Items", nopLogger{}, nil)
+		failure := test.status
+		addMethod(t, adapter, "Get", rgrpc.MethodConfig{Request: wrapperspb.StringValue{}},
+			func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+				return output{code: failure, err: errors.New("cause")}
+			})
+		conn := serve(t, adapter)
+
+		var trailer metadata.MD
+		err := conn.Invoke(context.Background(), "/items.v1.Items/Get", &wrapperspb.StringValue{}, &wrapperspb.StringValue{}, grpc.Trailer(&trailer))
+
+		st := status.Convert(err)
+		if st.Code() != test.code || st.Message() != "Service failed" {
+			t.Errorf("Expected %s for status %d, got %v", test.code, test.status, err)
+		}
+
+		// the trailers carry the api error of the service
+		if apiErr := trailer.Get(rgrpc.MetadataApiError); len(apiErr) != 1 || apiErr[0] != strconv.Itoa(int(test.status)) {
+			t.Errorf("Unexpected api error trailer %v", apiErr)
+		}
+
+		if devMsg := trailer.Get(rgrpc.MetadataDevMsg); len(devMsg) != 1 || devMsg[0] != "cause" {
+			t.Errorf("Unexpected dev message trailer %v", devMsg)
+		}
+
+		if corrID := trailer.Get(rgrpc.MetadataCorrID); len(corrID) != 1 || corrID[0] == "" {
+			t.Errorf("Missing correlation ID trailer %v", corrID)
+		}
+	}
+}
+
+func TestAddMethodRejectsInvalidConfig(t *testing.T) {
+	adapter := rgrpc.New("items.v1.Items", nopLogger{}, nil)
+
+	if err := adapter.AddMethod("Get", rgrpc.MethodConfig{}, nil); err == nil {
+		t.Error("AddMethod accepted a method without request type")
+	}
+
+	if err := adapter.AddMethod("Get", rgrpc.MethodConfig{Request: wrapperspb.StringValue{}, Tx: api.ConfigTxManaged}, nil); err == nil {
+		t.Error("AddMethod accepted a transactional method without storage")
+	}
+}
@@ -0,0 +1,40 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc/metadata"
+)
+
+type grpcServiceInput struct {
+	ctx     context.Context
+	request interface{}
+	md      metadata.MD
+}
+
+func (g grpcServiceInput) RawCtx() interface{} {
+	return g.ctx
+}
+
+func (g grpcServiceInput) Model() interface{} {
+	if g.request == nil {
+		panic("Missing required Input Model. Is method config correct?")
+	}
+
+	return g.request
+}
+
+// gRPC methods have no path parameters, every input is part of the request message
+func (g grpcServiceInput) InputParams() map[string]string {
+	return map[string]string{}
+}
+
+// gRPC methods have no query string, every input is part of the request message
+func (g grpcServiceInput) QueryParams() interface{} {
+	return nil
+}
+
+// Headers returns the incoming metadata.MD of the call
+func (g grpcServiceInput) Headers() interface{} {
+	return g.md
+}
@@ -0,0 +1,28 @@
+package grpc
+
+import (
+	"github.com/hellcats88/abstracte/api"
+	"google.golang.org/grpc/codes"
+)
+
+// Code maps a service status into the equivalent gRPC status code
+func Code(status api.ApiError) codes.Code {
+	switch status {
+	case api.ApiErrorNoError:
+		return codes.OK
+	case api.ApiErrorAuthFailed:
+		return codes.PermissionDenied
+	case api.ApiErrorEntityAlreadyExists:
+		return codes.AlreadyExists
+	case api.ApiErrorEntityDoesNotExists:
+		return codes.NotFound
+	case api.ApiErrorMissingRequiredItem:
+		return codes.InvalidArgument
+	case api.ApiErrorUnknownItemRequested:
+		return codes.InvalidArgument
+	case api.ApiErrorUnexpected:
+		return codes.Internal
+	}
+
+	return codes.Unknown
+}
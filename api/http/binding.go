@@ -0,0 +1,225 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// bindJSON decodes the request body into obj and validates its binding tags
+func bindJSON(c *Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return errors.New("invalid request")
+	}
+
+	if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil {
+		return err
+	}
+
+	return validate(obj)
+}
+
+// bindHeaders fills obj from the request headers using the "header" field tag
+func bindHeaders(c *Context, obj interface{}) error {
+	err := bindValues(obj, "header", func(name string) ([]string, bool) {
+		values, exist := c.Request.Header[http.CanonicalHeaderKey(name)]
+		return values, exist
+	})
+	if err != nil {
+		return err
+	}
+
+	return validate(obj)
+}
+
+// bindQuery fills obj from the query string using the "form" field tag
+func bindQuery(c *Context, obj interface{}) error {
+	query := c.Request.URL.Query()
+	err := bindValues(obj, "form", func(name string) ([]string, bool) {
+		values, exist := query[name]
+		return values, exist
+	})
+	if err != nil {
+		return err
+	}
+
+	return validate(obj)
+}
+
+func bindValues(obj interface{}, tag string, lookup func(string) ([]string, bool)) error {
+	value := reflect.ValueOf(obj)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("binding target must be a pointer to struct")
+	}
+
+	return bindStruct(value.Elem(), tag, lookup)
+}
+
+func bindStruct(value reflect.Value, tag string, lookup func(string) ([]string, bool)) error {
+	t := value.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, defaultValue := parseTag(field.Tag.Get(tag))
+		if name == "-" {
+			continue
+		}
+
+		if name == "" && field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			if err := bindStruct(value.Field(i), tag, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		values, exist := lookup(name)
+		if !exist || len(values) == 0 {
+			if defaultValue == "" {
+				continue
+			}
+			values = []string{defaultValue}
+		}
+
+		if err := setField(value.Field(i), values); err != nil {
+			return fmt.Errorf("cannot bind %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func parseTag(tag string) (string, string) {
+	parts := strings.Split(tag, ",")
+	defaultValue := ""
+
+	for _, p := range parts[1:] {
+		if strings.HasPrefix(p, "default=") {
+			defaultValue = strings.TrimPrefix(p, "default=")
+		}
+	}
+
+	return parts[0], defaultValue
+}
+
+func setField(field reflect.Value, values []string) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		ptr := reflect.New(field.Type().Elem())
+		if err := setField(ptr.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setField(slice.Index(i), []string{v}); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setScalar(field, values[0])
+}
+
+func setScalar(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	if field.Type() == reflect.TypeOf(time.Time{}) {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
+
+// structValidator applies the binding tags with the same rules gin enforces on the models
+var structValidator = newStructValidator()
+
+func newStructValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	return v
+}
+
+// validate checks obj against its binding tags (required, min, max, email, oneof, dive...)
+func validate(obj interface{}) error {
+	value := reflect.ValueOf(obj)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return structValidator.Struct(obj)
+}
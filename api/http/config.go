@@ -0,0 +1,140 @@
+package http
+
+import (
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/storage"
+)
+
+type httpConfig struct {
+	log         HandlerFunc
+	tenant      HandlerFunc
+	tx          HandlerFunc
+	commit      HandlerFunc
+	headers     HandlerFunc
+	model       HandlerFunc
+	params      HandlerFunc
+	queryParams HandlerFunc
+	beforeRun   []HandlerFunc
+	afterRun    []HandlerFunc
+}
+
+func (h httpConfig) Valid() bool {
+	return true
+}
+
+type httpConfigBuilder struct {
+	config httpConfig
+	log    logging.Logger
+	db     storage.Context
+}
+
+// NewConfigBuilder creates a route configuration builder. Routes built without
+// a storage context cannot use managed or unmanaged transactions
+func NewConfigBuilder(log logging.Logger) api.ConfigBuilder {
+	return NewConfigBuilderWithStorage(log, nil)
+}
+
+// NewConfigBuilderWithStorage creates a route configuration builder opening transactions from db
+func NewConfigBuilderWithStorage(log logging.Logger, db storage.Context) api.ConfigBuilder {
+	return &httpConfigBuilder{
+		log: log,
+		db:  db,
+		config: httpConfig{
+			log:    logHandler{}.createLogContext,
+			tx:     transactionHandler{log: log}.createNoTransaction,
+			tenant: tenantHandler{log: log}.createNoTenant,
+		},
+	}
+}
+
+func (b *httpConfigBuilder) Log(p api.ConfigLog) api.ConfigBuilder {
+	b.config.log = logHandler{}.createLogContext
+	return b
+}
+
+func (b *httpConfigBuilder) CustomLog(p api.C) api.ConfigBuilder {
+	b.config.log = p.Handler.(HandlerFunc)
+	return b
+}
+
+func (b *httpConfigBuilder) Tenant(p api.ConfigTenant) api.ConfigBuilder {
+	if p == api.ConfigTenantFromHeaders {
+		b.config.tenant = tenantHandler{log: b.log}.createTenantFromHeaders
+	}
+	return b
+}
+
+func (b *httpConfigBuilder) CustomTenant(p api.C) api.ConfigBuilder {
+	b.config.tenant = p.Handler.(HandlerFunc)
+	return b
+}
+
+func (b *httpConfigBuilder) Tx(p api.ConfigTx) api.ConfigBuilder {
+	if p == api.ConfigTxManaged {
+		b.config.tx = transactionHandler{log: b.log, db: b.db}.createManagedTransaction
+		b.config.commit = transactionHandler{log: b.log, db: b.db}.createCommitTx
+	} else if p == api.ConfigTxUnmanaged {
+		b.config.tx = transactionHandler{log: b.log, db: b.db}.createUnmanagedTransaction
+	}
+	return b
+}
+
+func (b *httpConfigBuilder) CustomTx(p api.C) api.ConfigBuilder {
+	b.config.tx = p.Handler.(HandlerFunc)
+	return b
+}
+
+func (b *httpConfigBuilder) Headers(p interface{}) api.ConfigBuilder {
+	b.config.headers = headersHandler{requestedModel: p, log: b.log}.loadHeaders
+	return b
+}
+
+func (b *httpConfigBuilder) CustomHeaders(p api.C) api.ConfigBuilder {
+	b.config.headers = p.Handler.(HandlerFunc)
+	return b
+}
+
+func (b *httpConfigBuilder) InputModel(p interface{}) api.ConfigBuilder {
+	b.config.model = modelHandler{requestedModel: p}.getModel
+	return b
+}
+
+func (b *httpConfigBuilder) CustomInputModel(p api.C) api.ConfigBuilder {
+	b.config.model = p.Handler.(HandlerFunc)
+	return b
+}
+
+func (b *httpConfigBuilder) InputParams(name []string) api.ConfigBuilder {
+	b.config.params = inputParamsHandler{requestedInputParams: name}.loadParams
+	return b
+}
+
+func (b *httpConfigBuilder) CustomInputParam(p api.C) api.ConfigBuilder {
+	b.config.params = p.Handler.(HandlerFunc)
+	return b
+}
+
+func (b *httpConfigBuilder) QueryParams(p interface{}) api.ConfigBuilder {
+	b.config.queryParams = queryParamsHandler{requestedModel: p, log: b.log}.getQueryParams
+	return b
+}
+
+func (b *httpConfigBuilder) CustomQueryParams(p api.C) api.ConfigBuilder {
+	b.config.queryParams = p.Handler.(HandlerFunc)
+	return b
+}
+
+func (b *httpConfigBuilder) CustomBeforeRun(p api.C) api.ConfigBuilder {
+	b.config.beforeRun = append(b.config.beforeRun, p.Handler.(HandlerFunc))
+	return b
+}
+
+func (b *httpConfigBuilder) CustomAfterRun(p api.C) api.ConfigBuilder {
+	b.config.afterRun = append(b.config.afterRun, p.Handler.(HandlerFunc))
+	return b
+}
+
+func (b *httpConfigBuilder) Build() api.Config {
+	return b.config
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+)
+
+const abortIndex = math.MaxInt8 / 2
+
+// HandlerFunc is a pipeline stage. Custom stages passed through api.C must use this type
+type HandlerFunc func(*Context)
+
+// Context carries the state of a request through the pipeline stages,
+// with the same Next/Abort semantics of gin middlewares
+type Context struct {
+	Writer  http.ResponseWriter
+	Request *http.Request
+
+	keys     map[string]interface{}
+	params   map[string]string
+	handlers []HandlerFunc
+	index    int
+	status   int
+	written  bool
+}
+
+func newContext(w http.ResponseWriter, r *http.Request, handlers []HandlerFunc, params map[string]string) *Context {
+	return &Context{
+		Writer:   w,
+		Request:  r,
+		keys:     make(map[string]interface{}),
+		params:   params,
+		handlers: handlers,
+		index:    -1,
+		status:   http.StatusOK,
+	}
+}
+
+// Next runs the remaining stages of the pipeline. It must be called by stages
+// that need to execute logic after the following ones
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// Abort prevents the remaining stages from running. Stages already running are not stopped
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted reports if the pipeline has been aborted
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// Set stores a value in the request context
+func (c *Context) Set(key string, value interface{}) {
+	c.keys[key] = value
+}
+
+// Get returns a value stored in the request context
+func (c *Context) Get(key string) (interface{}, bool) {
+	value, exist := c.keys[key]
+	return value, exist
+}
+
+// Param returns the value of a path parameter
+func (c *Context) Param(name string) string {
+	return c.params[name]
+}
+
+// GetHeader returns the value of a request header
+func (c *Context) GetHeader(name string) string {
+	return c.Request.Header.Get(name)
+}
+
+// Header sets a response header
+func (c *Context) Header(name string, value string) {
+	c.Writer.Header().Set(name, value)
+}
+
+// Written reports if the response has already been sent
+func (c *Context) Written() bool {
+	return c.written
+}
+
+// JSON writes the JSON serialization of obj with the given status code
+func (c *Context) JSON(code int, obj interface{}) {
+	if c.written {
+		return
+	}
+
+	c.written = true
+	c.status = code
+	c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	c.Writer.WriteHeader(code)
+	json.NewEncoder(c.Writer).Encode(obj)
+}
+
+// AbortWithStatus aborts the pipeline and writes the status code without body
+func (c *Context) AbortWithStatus(code int) {
+	c.Abort()
+	if !c.written {
+		c.written = true
+		c.status = code
+		c.Writer.WriteHeader(code)
+	}
+}
+
+// AbortWithStatusJSON aborts the pipeline and writes obj as JSON
+func (c *Context) AbortWithStatusJSON(code int, obj interface{}) {
+	c.Abort()
+	c.JSON(code, obj)
+}
@@ -0,0 +1,9 @@
+module github.com/hellcats88/rem/api/http
+
+go 1.15
+
+require (
+	github.com/go-playground/validator/v10 v10.4.1
+	github.com/hellcats88/abstracte v0.0.0-20210531083423-6e6de49b09ed
+	github.com/hellcats88/rem v0.0.0-20210317090745-a31d710583e4
+)
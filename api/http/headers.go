@@ -0,0 +1,28 @@
+package http
+
+import (
+	"reflect"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+)
+
+type headersHandler struct {
+	requestedModel interface{}
+	log            logging.Logger
+}
+
+func (h headersHandler) loadHeaders(ctx *Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	emptyModel := reflect.New(reflect.TypeOf(h.requestedModel)).Interface()
+
+	if err := bindHeaders(ctx, emptyModel); err != nil {
+		h.log.Warn(logCtx, "No headers found to be parsed. %v", err)
+	}
+
+	ctx.Set(api.HeadersModelKey, emptyModel)
+	ctx.Next()
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/runtime"
+)
+
+// Http extends api.Http with http.Handler, so routes can be mounted
+// on any net/http server or served in process
+type Http interface {
+	api.Http
+	http.Handler
+}
+
+type stdHttp struct {
+	router *router
+	log    logging.Logger
+}
+
+func New(log logging.Logger) Http {
+	return stdHttp{
+		router: &router{},
+		log:    log,
+	}
+}
+
+func reverse(items []HandlerFunc) []HandlerFunc {
+	for i := 0; i < len(items)/2; i++ {
+		j := len(items) - i - 1
+		items[i], items[j] = items[j], items[i]
+	}
+	return items
+}
+
+func (h stdHttp) wrapService(service api.Service) HandlerFunc {
+	return func(c *Context) {
+		// ignore exist result because runtime context is mandatory
+		// and the user cannot remove it
+		ctx, _ := c.Get(api.RuntimeKey)
+		rCtx := ctx.(runtime.Context)
+
+		output := service(rCtx, httpServiceInput{ctx: c})
+		c.Set(api.ServiceResultKey, output)
+	}
+}
+
+func (h stdHttp) AddRoute(method string, path string, config api.Config, service api.Service) error {
+	var handlers []HandlerFunc
+	httpCnf, ok := config.(httpConfig)
+	if !ok {
+		return fmt.Errorf("Route %s %s needs a configuration created by this package", method, path)
+	}
+
+	handlers = append(handlers, httpCnf.log, httpCnf.tenant, httpCnf.tx)
+
+	if httpCnf.headers != nil {
+		handlers = append(handlers, httpCnf.headers)
+	}
+
+	if httpCnf.model != nil {
+		handlers = append(handlers, httpCnf.model)
+	}
+
+	if httpCnf.params != nil {
+		handlers = append(handlers, httpCnf.params)
+	}
+
+	if httpCnf.queryParams != nil {
+		handlers = append(handlers, httpCnf.queryParams)
+	}
+
+	if len(httpCnf.beforeRun) > 0 {
+		handlers = append(handlers, httpCnf.beforeRun...)
+	}
+
+	handlers = append(handlers, runtimeHandler{}.createRuntimeContext, h.wrapService(service))
+
+	//reverse order due to recursive logic of the pipeline stages
+	handlers = append(handlers, resultHandler{log: h.log}.handleResult)
+
+	if len(httpCnf.afterRun) > 0 {
+		handlers = append(handlers, reverse(append([]HandlerFunc(nil), httpCnf.afterRun...))...)
+	}
+
+	if httpCnf.commit != nil {
+		handlers = append(handlers, httpCnf.commit)
+	}
+
+	h.router.add(method, path, handlers)
+	return nil
+}
+
+func (h stdHttp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.router.ServeHTTP(w, r)
+}
+
+func (h stdHttp) Listen(port int, address string) error {
+	return http.ListenAndServe(fmt.Sprintf("%s:%d", address, port), h.router)
+}
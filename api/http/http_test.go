@@ -0,0 +1,249 @@
+package http_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/runtime"
+	rhttp "github.com/hellcats88/rem/api/http"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Info(ctx logging.Context, msg string, params ...interface{})                 {}
+func (nopLogger) Debug(ctx logging.Context, msg string, params ...interface{})                {}
+func (nopLogger) Trace(ctx logging.Context, msg string, params ...interface{})                {}
+func (nopLogger) Error(ctx logging.Context, msg string, params ...interface{})                {}
+func (nopLogger) Warn(ctx logging.Context, msg string, params ...interface{})                 {}
+func (nopLogger) BeginMethod(ctx logging.Context)                                             {}
+func (nopLogger) BeginMethodParams(ctx logging.Context, format string, params ...interface{}) {}
+func (nopLogger) EndMethod(ctx logging.Context)                                               {}
+func (nopLogger) EndMethodParams(ctx logging.Context, format string, params ...interface{})   {}
+
+type output struct {
+	code api.ApiError
+	err  error
+	data interface{}
+}
+
+func (o output) Status() api.ApiError       { return o.code }
+func (o output) Err() error                 { return o.err }
+func (o output) ErrMessage() string         { return "Service failed" }
+func (o output) ResponseModel() interface{} { return o.data }
+
+type envelope struct {
+	Error api.ErrorModel  `json:"err"`
+	Data  json.RawMessage `json:"data"`
+}
+
+func serve(t *testing.T, h http.Handler, req *http.Request) (*httptest.ResponseRecorder, envelope) {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+
+	var env envelope
+	if recorder.Header().Get("Content-Type") == "application/json; charset=utf-8" {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &env); err != nil {
+			t.Fatalf("Invalid envelope %s. %v", recorder.Body.String(), err)
+		}
+	}
+
+	return recorder, env
+}
+
+func addRoute(t *testing.T, h rhttp.Http, method string, path string, config api.Config, service api.Service) {
+	t.Helper()
+
+	if err := h.AddRoute(method, path, config, service); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type itemModel struct {
+	Name  string `json:"name" binding:"required"`
+	Count int    `json:"count" binding:"gte=0"`
+}
+
+type itemQuery struct {
+	Page int    `form:"page"`
+	Sort string `form:"sort"`
+}
+
+type itemHeaders struct {
+	Env string `header:"X-Env"`
+}
+
+func TestRoutePipeline(t *testing.T) {
+	log := nopLogger{}
+	h := rhttp.New(log)
+
+	config := rhttp.NewConfigBuilder(log).
+		Tenant(api.ConfigTenantFromHeaders).
+		InputModel(itemModel{}).
+		InputParams([]string{"id"}).
+		QueryParams(itemQuery{}).
+		Headers(itemHeaders{}).
+		Build()
+
+	addRoute(t, h, http.MethodPut, "/items/:id", config, func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+		return output{data: map[string]interface{}{
+			"tenant":  ctx.Tenant().ID(),
+			"id":      input.InputParams()["id"],
+			"model":   input.Model(),
+			"query":   input.QueryParams(),
+			"headers": input.Headers(),
+		}}
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/items/i%2F1?page=2&sort=name", bytes.NewBufferString(`{"name":"a","count":3}`))
+	req.Header.Set("X-Tenant-ID", "t1")
+	req.Header.Set("X-Tenant-UserID", "u1")
+	req.Header.Set("X-Env", "dev")
+
+	recorder, env := serve(t, h, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d. %s", recorder.Code, recorder.Body.String())
+	}
+
+	var data struct {
+		Tenant  string      `json:"tenant"`
+		ID      string      `json:"id"`
+		Model   itemModel   `json:"model"`
+		Query   itemQuery   `json:"query"`
+		Headers itemHeaders `json:"headers"`
+	}
+	json.Unmarshal(env.Data, &data)
+
+	if data.Tenant != "t1" || data.ID != "i/1" || data.Model != (itemModel{Name: "a", Count: 3}) ||
+		data.Query != (itemQuery{Page: 2, Sort: "name"}) || data.Headers.Env != "dev" {
+		t.Errorf("Unexpected service input %+v", data)
+	}
+}
+
+func TestRouteRejectsInvalidInput(t *testing.T) {
+	log := nopLogger{}
+	h := rhttp.New(log)
+
+	addRoute(t, h, http.MethodPost, "/items", rhttp.NewConfigBuilder(log).Tenant(api.ConfigTenantFromHeaders).InputModel(itemModel{}).Build(),
+		func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			return output{data: input.Model()}
+		})
+
+	tests := []struct {
+		name   string
+		body   string
+		tenant bool
+		status int
+		code   api.ApiError
+	}{
+		{"missing tenant", `{"name":"a"}`, false, http.StatusUnauthorized, api.ApiErrorAuthFailed},
+		{"malformed body", `{"name":`, true, http.StatusBadRequest, api.ApiErrorUnexpected},
+		{"missing required", `{"count":1}`, true, http.StatusBadRequest, api.ApiErrorUnexpected},
+		{"failed rule", `{"name":"a","count":-1}`, true, http.StatusBadRequest, api.ApiErrorUnexpected},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/items", bytes.NewBufferString(test.body))
+			if test.tenant {
+				req.Header.Set("X-Tenant-ID", "t1")
+				req.Header.Set("X-Tenant-UserID", "u1")
+			}
+
+			recorder, env := serve(t, h, req)
+			if recorder.Code != test.status || env.Error.Code != test.code {
+				t.Errorf("Expected %d with code %d, got %d. %s", test.status, test.code, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestServiceFailures(t *testing.T) {
+	log := nopLogger{}
+	h := rhttp.New(log)
+
+	tests := []struct {
+		path   string
+		output output
+		status int
+	}{
+		{"/missing", output{code: api.ApiErrorEntityDoesNotExists, err: errors.New("No item")}, http.StatusNotFound},
+		{"/exists", output{code: api.ApiErrorEntityAlreadyExists, err: errors.New("Duplicate")}, http.StatusConflict},
+		{"/unexpected", output{code: api.ApiErrorUnexpected}, http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		out := test.output
+		addRoute(t, h, http.MethodGet, test.path, rhttp.NewConfigBuilder(log).Build(), func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			return out
+		})
+	}
+
+	for _, test := range tests {
+		recorder, env := serve(t, h, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if recorder.Code != test.status || env.Error.Code != test.output.code {
+			t.Errorf("%s: expected %d, got %d. %s", test.path, test.status, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestRouting(t *testing.T) {
+	log := nopLogger{}
+	h := rhttp.New(log)
+
+	route := func(name string) api.Service {
+		return func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			return output{data: name}
+		}
+	}
+
+	addRoute(t, h, http.MethodGet, "/items/:id", rhttp.NewConfigBuilder(log).Build(), route("item"))
+	addRoute(t, h, http.MethodGet, "/items/new", rhttp.NewConfigBuilder(log).Build(), route("new"))
+	addRoute(t, h, http.MethodGet, "/files/*path", rhttp.NewConfigBuilder(log).InputParams([]string{"path"}).Build(),
+		func(ctx runtime.Context, input api.ServiceInput) api.ServiceOutput {
+			return output{data: input.InputParams()["path"]}
+		})
+	addRoute(t, h, http.MethodDelete, "/items/:id", rhttp.NewConfigBuilder(log).Build(), route("delete"))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		data   string
+	}{
+		{http.MethodGet, "/items/1", http.StatusOK, `"item"`},
+		{http.MethodGet, "/items/new", http.StatusOK, `"new"`},
+		{http.MethodGet, "/files/a/b.txt", http.StatusOK, `"a/b.txt"`},
+		{http.MethodDelete, "/items/1", http.StatusOK, `"delete"`},
+		{http.MethodHead, "/items/1", http.StatusOK, ""},
+		{http.MethodPost, "/items/1", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/items", http.StatusNotFound, ""},
+		{http.MethodGet, "/items/1/more", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		recorder, env := serve(t, h, httptest.NewRequest(test.method, test.path, nil))
+		if recorder.Code != test.status {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.path, test.status, recorder.Code)
+		}
+
+		if test.data != "" && string(env.Data) != test.data {
+			t.Errorf("%s %s: expected %s, got %s", test.method, test.path, test.data, env.Data)
+		}
+	}
+}
+
+func TestAddRouteRejectsForeignConfig(t *testing.T) {
+	h := rhttp.New(nopLogger{})
+
+	if err := h.AddRoute(http.MethodGet, "/", nil, nil); err == nil {
+		t.Error("AddRoute accepted a configuration of another package")
+	}
+}
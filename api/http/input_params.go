@@ -0,0 +1,41 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+)
+
+type inputParamsHandler struct {
+	requestedInputParams []string
+}
+
+func (h inputParamsHandler) loadParams(ctx *Context) {
+	params := make(map[string]string)
+
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	for _, p := range h.requestedInputParams {
+		pV := ctx.Param(p)
+		if pV == "" {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorMissingRequiredItem,
+					Msg:    "Missing part of URL",
+					DevMsg: fmt.Sprintf("Cannot find parameter %s", p),
+					CorrId: logCtx.CorrID(),
+				},
+			})
+			return
+		}
+
+		params[p] = pV
+	}
+
+	ctx.Set(api.InputParamsKey, params)
+	ctx.Next()
+}
@@ -0,0 +1,23 @@
+package http
+
+import (
+	"github.com/hellcats88/abstracte/api"
+	alog "github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/rem/logging"
+)
+
+type logHandler struct {
+}
+
+func (h logHandler) createLogContext(ctx *Context) {
+	var lCtx alog.Context
+
+	if corrId := ctx.GetHeader("X-Correlation-ID"); corrId != "" {
+		lCtx = logging.NewContext(corrId)
+	} else {
+		lCtx = logging.NewContextUUID()
+	}
+
+	ctx.Set(api.LogKey, lCtx)
+	ctx.Next()
+}
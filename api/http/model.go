@@ -0,0 +1,37 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+)
+
+type modelHandler struct {
+	requestedModel interface{}
+}
+
+func (h modelHandler) getModel(ctx *Context) {
+	emptyModel := reflect.New(reflect.TypeOf(h.requestedModel)).Interface()
+
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	if err := bindJSON(ctx, emptyModel); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "API needs a valid payload",
+				DevMsg: fmt.Sprintf("Failed to transform payload model from JSON. %v", err),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	ctx.Set(api.InputModelKey, emptyModel)
+	ctx.Next()
+}
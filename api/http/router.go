@@ -0,0 +1,158 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// segment kinds, in increasing order of precedence when several routes match a path
+const (
+	segmentCatchAll = iota
+	segmentParam
+	segmentStatic
+)
+
+type routeSegment struct {
+	kind  int
+	value string
+}
+
+type route struct {
+	method   string
+	segments []routeSegment
+	handlers []HandlerFunc
+}
+
+// router dispatches the requests to the routes declared with gin style paths
+// (/items/:id/*rest). Static segments take precedence over parameters, which
+// take precedence over catch-all segments
+type router struct {
+	mu     sync.RWMutex
+	routes []*route
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func (r *router) add(method string, path string, handlers []HandlerFunc) {
+	parts := splitPath(path)
+	segments := make([]routeSegment, len(parts))
+
+	for i, p := range parts {
+		switch {
+		case strings.HasPrefix(p, ":"):
+			segments[i] = routeSegment{kind: segmentParam, value: p[1:]}
+		case strings.HasPrefix(p, "*"):
+			segments[i] = routeSegment{kind: segmentCatchAll, value: p[1:]}
+		default:
+			segments[i] = routeSegment{kind: segmentStatic, value: p}
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes = append(r.routes, &route{method: method, segments: segments, handlers: handlers})
+
+	// the most specific routes are tried first
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		return moreSpecific(r.routes[i].segments, r.routes[j].segments)
+	})
+}
+
+func moreSpecific(a []routeSegment, b []routeSegment) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i].kind != b[i].kind {
+			return a[i].kind > b[i].kind
+		}
+	}
+	return len(a) > len(b)
+}
+
+// match returns the path parameters of the request path if it matches the route
+func (rt *route) match(parts []string) (map[string]string, bool) {
+	params := make(map[string]string)
+
+	for i, s := range rt.segments {
+		if s.kind == segmentCatchAll {
+			value, err := url.PathUnescape(strings.Join(parts[i:], "/"))
+			if err != nil {
+				return nil, false
+			}
+			params[s.value] = value
+			return params, true
+		}
+
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		value, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, false
+		}
+
+		switch s.kind {
+		case segmentParam:
+			if value == "" {
+				return nil, false
+			}
+			params[s.value] = value
+		case segmentStatic:
+			if value != s.value {
+				return nil, false
+			}
+		}
+	}
+
+	return params, len(parts) == len(rt.segments)
+}
+
+// find returns the most specific route of method matching the request path
+func (r *router) find(method string, parts []string) (*route, map[string]string, []string) {
+	var allowed []string
+
+	for _, rt := range r.routes {
+		params, ok := rt.match(parts)
+		if !ok {
+			continue
+		}
+
+		if rt.method == method {
+			return rt, params, nil
+		}
+		allowed = append(allowed, rt.method)
+	}
+
+	return nil, nil, allowed
+}
+
+func (r *router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	parts := splitPath(req.URL.EscapedPath())
+
+	r.mu.RLock()
+	found, params, allowed := r.find(req.Method, parts)
+
+	// HEAD requests are served by the GET routes, unless a HEAD route exists
+	if found == nil && req.Method == http.MethodHead {
+		found, params, _ = r.find(http.MethodGet, parts)
+	}
+	r.mu.RUnlock()
+
+	if found != nil {
+		newContext(w, req, found.handlers, params).Next()
+		return
+	}
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	http.NotFound(w, req)
+}
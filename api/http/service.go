@@ -0,0 +1,52 @@
+package http
+
+import (
+	"github.com/hellcats88/abstracte/api"
+)
+
+type httpServiceInput struct {
+	ctx *Context
+}
+
+func (h httpServiceInput) RawCtx() interface{} {
+	return h.ctx
+}
+
+func (h httpServiceInput) Model() interface{} {
+	model, ok := h.ctx.Get(api.InputModelKey)
+	if !ok {
+		panic("Missing required Input Model. Is pipeline correct?")
+	}
+
+	return model
+}
+
+func (h httpServiceInput) InputParams() map[string]string {
+	model, ok := h.ctx.Get(api.InputParamsKey)
+	if !ok {
+		panic("Missing required Input Params. Is pipeline correct?")
+	}
+
+	return model.(map[string]string)
+}
+
+func (h httpServiceInput) QueryParams() interface{} {
+	model, ok := h.ctx.Get(api.QueryParamsKey)
+	if !ok {
+		panic("Missing required Query Params. Is pipeline correct?")
+	}
+
+	// Query params are optional, but the model must be stored in the context.
+	// Users should check if the model is valid using default value of the
+	// model.
+	return model
+}
+
+func (h httpServiceInput) Headers() interface{} {
+	model, ok := h.ctx.Get(api.HeadersModelKey)
+	if !ok {
+		panic("Missing required Headers. Is pipeline correct?")
+	}
+
+	return model
+}
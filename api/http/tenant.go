@@ -0,0 +1,42 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/rem/tenant"
+)
+
+type tenantHandler struct {
+	log logging.Logger
+}
+
+func (h tenantHandler) createNoTenant(ctx *Context) {
+	ctx.Set(api.TenantKey, tenant.NewEmpty())
+	ctx.Next()
+}
+
+func (h tenantHandler) createTenantFromHeaders(ctx *Context) {
+	// logging key is always populated, don't check the exist return value.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+	tCtx := tenant.New(ctx.GetHeader("X-Tenant-ID"), ctx.GetHeader("X-Tenant-UserID"))
+
+	if tCtx.ID() == "" || tCtx.UserID() == "" {
+		h.log.Error(logCtx, "Rejected request caused by missing tenant informations")
+
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorAuthFailed,
+				Msg:    "Failed to get user information",
+				DevMsg: "Missing X-Tenant-ID or X-Tenant-UserID headers",
+				CorrId: logCtx.CorrID(),
+			},
+		})
+		return
+	}
+
+	ctx.Set(api.TenantKey, tCtx)
+	ctx.Next()
+}
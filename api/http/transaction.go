@@ -0,0 +1,120 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/hellcats88/abstracte/api"
+	"github.com/hellcats88/abstracte/logging"
+	"github.com/hellcats88/abstracte/runtime"
+	"github.com/hellcats88/abstracte/storage"
+)
+
+type txNoOp struct{}
+
+func (txNoOp) Ref() interface{}                    { return nil }
+func (txNoOp) Commit() error                       { return nil }
+func (txNoOp) Rollback() error                     { return nil }
+func (txNoOp) Begin() (storage.Transaction, error) { return nil, nil }
+func (txNoOp) Query() interface{}                  { return nil }
+
+type transactionHandler struct {
+	log logging.Logger
+	db  storage.Context
+}
+
+func (h transactionHandler) createNoTransaction(ctx *Context) {
+	ctx.Set(api.TxKey, txNoOp{})
+	ctx.Next()
+}
+
+func (h transactionHandler) createManagedTransaction(ctx *Context) {
+	tx, err := h.db.Tx()
+
+	// logging key is always populated, don't check the exist return value.
+	// Runtime context is not available yet, it needs the transaction.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Failed to open new managed transaction",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+
+		return
+	}
+
+	ctx.Set(api.TxKey, tx)
+	ctx.Next()
+}
+
+func (h transactionHandler) createUnmanagedTransaction(ctx *Context) {
+	tx, err := h.db.UnmanagedTx()
+
+	// logging key is always populated, don't check the exist return value.
+	// Runtime context is not available yet, it needs the transaction.
+	iLogCtx, _ := ctx.Get(api.LogKey)
+	logCtx := iLogCtx.(logging.Context)
+
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, api.Model{
+			Error: api.ErrorModel{
+				Code:   api.ApiErrorUnexpected,
+				Msg:    "Failed to open new unmanaged transaction",
+				DevMsg: err.Error(),
+				CorrId: logCtx.CorrID(),
+			},
+		})
+
+		return
+	}
+
+	ctx.Set(api.TxKey, tx)
+	ctx.Next()
+}
+
+func (h transactionHandler) createCommitTx(ctx *Context) {
+	ctx.Next()
+
+	result, _ := ctx.Get(api.ServiceResultKey)
+	svcRes := result.(api.ServiceOutput)
+
+	tx, _ := ctx.Get(api.TxKey)
+	svcTx := tx.(storage.Transaction)
+
+	rCtx, _ := ctx.Get(api.RuntimeKey)
+	svcCtx := rCtx.(runtime.Context)
+
+	if svcRes.Status() != api.ApiErrorNoError {
+		err := svcTx.Rollback()
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorUnexpected,
+					Msg:    "Failed to rollback changes",
+					DevMsg: err.Error(),
+					CorrId: svcCtx.Log().CorrID(),
+				},
+			})
+			return
+		}
+
+	} else {
+		err := svcTx.Commit()
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, api.Model{
+				Error: api.ErrorModel{
+					Code:   api.ApiErrorUnexpected,
+					Msg:    "Failed to commit changes",
+					DevMsg: err.Error(),
+					CorrId: svcCtx.Log().CorrID(),
+				},
+			})
+			return
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package feature
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// FileProvider loads the flags from a JSON file mapping flag names to their definition, e.g.
+//
+//	{"new-checkout": {"enabled": true, "envs": ["staging"], "percentage": 20}}
+type FileProvider struct {
+	Path string
+}
+
+// NewFileProvider creates a provider reading the flags from path
+func NewFileProvider(path string) FileProvider {
+	return FileProvider{Path: path}
+}
+
+func (p FileProvider) Flags() (map[string]Flag, error) {
+	data, err := ioutil.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var flags map[string]Flag
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("Invalid feature flags file %s. %v", p.Path, err)
+	}
+
+	return flags, nil
+}
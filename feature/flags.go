@@ -0,0 +1,172 @@
+package feature
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/hellcats88/abstracte/runtime"
+)
+
+// Rollout units of the percentage rollouts
+const (
+	RolloutByTenant = "tenant"
+	RolloutByUser   = "user"
+)
+
+// Flag defines when a feature is enabled.
+// A disabled flag is off for everyone. Otherwise, outside of Envs it is off, for the listed
+// Tenants and Users it is on, and for everybody else it is on for Percentage of the tenants
+// (or users, see RolloutBy). A flag without targeting and percentage is on for everyone
+type Flag struct {
+	Enabled    bool     `json:"enabled"`
+	Envs       []string `json:"envs,omitempty"`
+	Tenants    []string `json:"tenants,omitempty"`
+	Users      []string `json:"users,omitempty"`
+	Percentage int      `json:"percentage,omitempty"`
+	RolloutBy  string   `json:"rolloutBy,omitempty"`
+}
+
+// Target identifies who a flag is evaluated for
+type Target struct {
+	TenantID string
+	UserID   string
+	Env      string
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// bucket spreads the rollout units uniformly on 0-99, consistently for the same flag
+func bucket(name string, unit string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + ":" + unit))
+	return int(h.Sum32() % 100)
+}
+
+// Evaluate reports if flag name is on for target
+func (f Flag) Evaluate(name string, target Target) bool {
+	if !f.Enabled {
+		return false
+	}
+
+	if len(f.Envs) > 0 && !contains(f.Envs, target.Env) {
+		return false
+	}
+
+	if contains(f.Tenants, target.TenantID) || contains(f.Users, target.UserID) {
+		return true
+	}
+
+	if len(f.Tenants) == 0 && len(f.Users) == 0 && f.Percentage == 0 {
+		return true
+	}
+
+	unit := target.TenantID
+	if f.RolloutBy == RolloutByUser {
+		unit = target.TenantID + "/" + target.UserID
+	}
+
+	return bucket(name, unit) < f.Percentage
+}
+
+// Provider loads the flag definitions, keyed by flag name
+type Provider interface {
+	Flags() (map[string]Flag, error)
+}
+
+// Flags evaluates the feature flags for the caller of a runtime context
+type Flags interface {
+	Enabled(name string) bool
+}
+
+// Store holds the flags loaded from a provider. It is safe for concurrent use
+type Store struct {
+	provider Provider
+	mu       sync.RWMutex
+	flags    map[string]Flag
+}
+
+// NewStore creates a store loading the flags from provider
+func NewStore(provider Provider) (*Store, error) {
+	s := &Store{provider: provider}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces the flags with the ones returned by the provider.
+// On error the current flags are kept
+func (s *Store) Reload() error {
+	flags, err := s.provider.Flags()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.flags = flags
+	s.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the flags every interval until stop is called. Reload errors are passed to onError, if not nil
+func (s *Store) Watch(interval time.Duration, onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// Evaluate reports if flag name is on for target. Unknown flags are off
+func (s *Store) Evaluate(name string, target Target) bool {
+	s.mu.RLock()
+	flag, exist := s.flags[name]
+	s.mu.RUnlock()
+
+	return exist && flag.Evaluate(name, target)
+}
+
+// For returns the flags evaluated for target
+func (s *Store) For(target Target) Flags {
+	return targetFlags{store: s, target: target}
+}
+
+type targetFlags struct {
+	store  *Store
+	target Target
+}
+
+func (f targetFlags) Enabled(name string) bool {
+	return f.store != nil && f.store.Evaluate(name, f.target)
+}
+
+// FromContext returns the flags of a runtime context created with flags,
+// otherwise flags always off
+func FromContext(ctx runtime.Context) Flags {
+	if withFlags, ok := ctx.(interface{ Flags() Flags }); ok {
+		return withFlags.Flags()
+	}
+	return targetFlags{}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/hellcats88/abstracte/storage"
 	"github.com/hellcats88/abstracte/tenant"
 	rem "github.com/hellcats88/rem/env"
+	"github.com/hellcats88/rem/feature"
 )
 
 // Context defines a runtime group of common information
@@ -15,6 +16,7 @@ type context struct {
 	tx     storage.Transaction
 	tenant tenant.Context
 	env    env.Context
+	flags  *feature.Store
 }
 
 func (c context) Log() logging.Context {
@@ -33,6 +35,11 @@ func (c context) Env() env.Context {
 	return c.env
 }
 
+// Flags evaluates the feature flags for tenant, user and environment of the context
+func (c context) Flags() feature.Flags {
+	return c.flags.For(feature.Target{TenantID: c.tenant.ID(), UserID: c.tenant.UserID(), Env: c.env.Name()})
+}
+
 // New creates an instance of runtime context with the associated logging info
 func New(log logging.Context, tx storage.Transaction, tenant tenant.Context) runtime.Context {
 	return context{
@@ -53,12 +60,29 @@ func NewWithEnv(log logging.Context, tx storage.Transaction, tenant tenant.Conte
 	}
 }
 
+// NewWithFlags creates an instance of runtime context for a specific environment evaluating the feature flags of store
+func NewWithFlags(log logging.Context, tx storage.Transaction, tenant tenant.Context, env env.Context, flags *feature.Store) runtime.Context {
+	return context{
+		log:    log,
+		tx:     tx,
+		tenant: tenant,
+		env:    env,
+		flags:  flags,
+	}
+}
+
 // New clones a context using new transaction
 func NewFromTx(from runtime.Context, tx storage.Transaction) runtime.Context {
-	return context{
+	c := context{
 		log:    from.Log(),
 		env:    from.Env(),
 		tx:     tx,
 		tenant: from.Tenant(),
 	}
+
+	if withFlags, ok := from.(context); ok {
+		c.flags = withFlags.flags
+	}
+
+	return c
 }
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/hellcats88/abstracte/storage"
+)
+
+// IsolationLevel is the isolation level of a transaction
+type IsolationLevel int
+
+// Isolation levels, in the same order as database/sql
+const (
+	IsolationDefault IsolationLevel = iota
+	IsolationReadUncommitted
+	IsolationReadCommitted
+	IsolationWriteCommitted
+	IsolationRepeatableRead
+	IsolationSnapshot
+	IsolationSerializable
+	IsolationLinearizable
+)
+
+func (l IsolationLevel) String() string {
+	return sql.IsolationLevel(l).String()
+}
+
+// TxOptions defines how a transaction is opened
+type TxOptions struct {
+	// Isolation level. Default: the one of the storage
+	Isolation IsolationLevel
+
+	// Opens a transaction rejecting writes
+	ReadOnly bool
+
+	// Maximum duration of the transaction, from its opening to its commit. Zero means no limit
+	Timeout time.Duration
+}
+
+// Sql returns the options for database/sql based storages
+func (o TxOptions) Sql() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: sql.IsolationLevel(o.Isolation), ReadOnly: o.ReadOnly}
+}
+
+// OptionsContext is implemented by the storage contexts able to open transactions with options.
+// ctx carries the deadline of the transaction, if any
+type OptionsContext interface {
+	storage.Context
+	TxWithOptions(ctx context.Context, options TxOptions) (storage.Transaction, error)
+	UnmanagedTxWithOptions(ctx context.Context, options TxOptions) (storage.Transaction, error)
+}